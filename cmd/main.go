@@ -1,10 +1,16 @@
 package main
 
 import (
+	"context"
 	"os"
+	"os/signal"
+	"strconv"
 	config "stream-session-api/internal/conf"
+	"stream-session-api/internal/media"
 	"stream-session-api/internal/service/worker"
 	"stream-session-api/pkg"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -21,12 +27,28 @@ func init() {
 
 	// Get config
 	if err := config.Get(); err != nil {
-		pkg.LogFatal("get config fail!")
+		pkg.LogFatal("get config fail!", "err", err)
 		os.Exit(1)
 	}
 }
 
 func main() {
+	// Cancelled on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Init tracing
+	shutdownTracer, err := pkg.InitTracer(context.Background())
+	if err != nil {
+		pkg.LogFatal("init tracer fail!", "err", err)
+		os.Exit(2)
+	}
+
+	// Init media backend
+	if err := media.Init(); err != nil {
+		pkg.LogFatal("init media backend fail!", "err", err)
+		os.Exit(2)
+	}
 
 	// Init gRPC server
 	if err := worker.InitGrpcServer(); err != nil {
@@ -34,7 +56,38 @@ func main() {
 		os.Exit(2)
 	}
 
+	// Init HTTP server
+	if err := worker.InitHttpServer(); err != nil {
+		pkg.LogFatal("init HTTP server fail!")
+		os.Exit(2)
+	}
+
+	// Re-create stream paths lost while we were down
+	if err := worker.RecoverStreamSessions(ctx); err != nil {
+		pkg.LogWarn("recover stream sessions fail!", "err", err)
+	}
+
 	go worker.GrpcServer()
-	go worker.PeriodicStreamSessionCheck()
-	select {}
+	go worker.HttpServer()
+	worker.PeriodicStreamSessionCheck(ctx)
+	worker.PeriodicRetentionCheck(ctx)
+	worker.MediaServerRestartCheck(ctx)
+
+	// Wait for signal
+	<-ctx.Done()
+	stop()
+
+	// Coordinated shutdown with deadline
+	timeout, err := strconv.ParseInt(os.Getenv("SHUTDOWN_TIMEOUT"), 10, 16)
+	if err != nil || timeout <= 0 {
+		timeout = 30
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second*time.Duration(timeout))
+	defer cancel()
+
+	worker.Shutdown(shutdownCtx)
+	if err := shutdownTracer(shutdownCtx); err != nil {
+		pkg.LogWarn("failed to flush traces")
+	}
+	pkg.LogInfo("bye")
 }
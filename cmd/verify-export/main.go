@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"stream-session-api/internal/evidence"
+)
+
+func main() {
+	os.Exit(verifyExport(os.Args[1:]))
+}
+
+// verifyExport checks the signature of a manifest and the hash of its clips,
+// offline and without any config
+func verifyExport(args []string) int {
+	flags := flag.NewFlagSet("verify-export", flag.ContinueOnError)
+	manifestPath := flags.String("manifest", "", "manifest file of the export")
+	publicKey := flags.String("key", "", "base64 Ed25519 public key of the signer, logged by dynastream at startup")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: verify-export -manifest <file> -key <public key> <clip>...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *manifestPath == "" || *publicKey == "" || flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	// Trusted key of the signer
+	key, err := evidence.PublicKey(*publicKey)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid key: %v\n", err)
+		return 2
+	}
+
+	manifest, err := evidence.Read(*manifestPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid manifest: %v\n", err)
+		return 2
+	}
+	if err := manifest.Verify(key); err != nil {
+		fmt.Fprintf(os.Stderr, "FAIL %s: %v\n", *manifestPath, err)
+		return 1
+	}
+
+	status := 0
+	for _, clip := range flags.Args() {
+		if err := manifest.VerifyFile(clip); err != nil {
+			fmt.Fprintf(os.Stderr, "FAIL %s: %v\n", clip, err)
+			status = 1
+			continue
+		}
+		fmt.Printf("OK %s\n", clip)
+	}
+	if status == 0 {
+		fmt.Printf("export %s of %s from %s to %s requested by %s\n",
+			manifest.ExportId, manifest.StreamId, manifest.Start, manifest.End, manifest.Principal)
+	}
+
+	return status
+}
@@ -0,0 +1,11 @@
+package domain
+
+import "time"
+
+// AlarmEvent is an event posted by an access-control or motion system
+type AlarmEvent struct {
+	Id     string    `json:"id"`     // Optional event id of the alarm system
+	Source string    `json:"source"` // Source id, mapped to the cameras by the alarms config
+	Type   string    `json:"type"`
+	Time   time.Time `json:"timestamp"` // Default to the reception time
+}
@@ -0,0 +1,26 @@
+package domain
+
+import "time"
+
+// Event types
+const (
+	EventStreamMigrated = "stream.migrated"
+)
+
+// Event notifies the owner of a stream session
+type Event struct {
+	Type     string    `json:"type"`
+	Owner    string    `json:"owner"`
+	StreamId string    `json:"stream_id"`
+	OldUrl   string    `json:"old_url"`
+	OldNode  string    `json:"old_node"`
+	NewUrl   string    `json:"new_url"`
+	NewNode  string    `json:"new_node"`
+	Time     time.Time `json:"time"`
+}
+
+type EventRepository interface {
+	Close()
+	Publish(event *Event) error
+	Subscribe(owner string) (<-chan *Event, error) // Closed when the repository is closed
+}
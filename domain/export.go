@@ -0,0 +1,26 @@
+package domain
+
+import "time"
+
+// Export is a clip of a recording assembled into a single file
+type Export struct {
+	Id        string        `json:"id"`
+	Owner     string        `json:"owner"`
+	Recording string        `json:"recording"` // Id of the recording
+	StreamId  string        `json:"stream_id"`
+	Start     time.Time     `json:"start"`
+	Duration  time.Duration `json:"duration"`
+	File      string        `json:"file"`
+	Manifest  string        `json:"manifest"` // Signed manifest of the file
+	Token     string        `json:"token"`    // Secret of the download url
+	CreatedAt time.Time     `json:"created_at"`
+	ExpiresAt time.Time     `json:"expires_at"`
+}
+
+type ExportRepository interface {
+	Close()
+	GetAll() ([]*Export, error)
+	FindById(id string) *Export
+	Insert(export *Export) error
+	Delete(id string) error
+}
@@ -0,0 +1,8 @@
+package domain
+
+// IceServer is a stun or turn server handed to a player
+type IceServer struct {
+	Urls       []string `json:"urls"`
+	Username   string   `json:"username"`
+	Credential string   `json:"credential"`
+}
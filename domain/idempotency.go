@@ -0,0 +1,25 @@
+package domain
+
+import "time"
+
+// Idempotency is the result of a StartStream request kept by its key
+type Idempotency struct {
+	Key        string            `json:"key"`
+	Owner      string            `json:"owner"`
+	StreamId   string            `json:"stream_id"`
+	Protocols  []string          `json:"protocols"` // Requested protocols, a replay must ask the same
+	Profile    string            `json:"profile"`
+	Url        string            `json:"url"` // Empty while the request is in progress
+	Tracks     []string          `json:"tracks"`
+	Urls       map[string]string `json:"urls"`
+	Token      string            `json:"token"`
+	IceServers []IceServer       `json:"ice_servers"`
+}
+
+type IdempotencyRepository interface {
+	Close()
+	FindByKey(owner, key string) *Idempotency
+	Reserve(result *Idempotency, ttl time.Duration) (bool, error) // False if the key is already taken
+	Save(result *Idempotency, ttl time.Duration) error
+	Delete(owner, key string) error
+}
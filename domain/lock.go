@@ -0,0 +1,10 @@
+package domain
+
+import "time"
+
+// LockRepository serializes work on a shared resource between instances
+type LockRepository interface {
+	Close()
+	Obtain(name string, ttl time.Duration) (string, bool, error) // Token of the lock, false if held by another
+	Release(name, token string) error
+}
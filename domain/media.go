@@ -0,0 +1,79 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// MediaInfo describes a running media server instance
+type MediaInfo struct {
+	Version string `json:"version"`
+	Started string `json:"started"` // Changes when the media server restarts
+}
+
+// Reader is a client reading a path of the media server
+type Reader struct {
+	Id        string `json:"id"`
+	Type      string `json:"type"` // webrtc, rtsp, rtsps, rtmp, rtmps, srt or hls
+	Path      string `json:"path"`
+	BytesSent uint64 `json:"bytes_sent"`
+}
+
+// PathStatus is the runtime state of a path
+type PathStatus struct {
+	Name          string   `json:"name"`
+	Source        string   `json:"source"`
+	Ready         bool     `json:"ready"`
+	Tracks        []string `json:"tracks"`
+	Readers       int      `json:"readers"`
+	BytesReceived uint64   `json:"bytes_received"`
+	BytesSent     uint64   `json:"bytes_sent"`
+}
+
+// Playback protocols of the stream session urls
+const (
+	ProtocolWhep   = "whep"   // WebRTC WHEP endpoint
+	ProtocolWebRtc = "webrtc" // WebRTC player page
+	ProtocolHls    = "hls"
+	ProtocolLlHls  = "llhls" // Low-latency HLS
+	ProtocolRtsp   = "rtsp"
+	ProtocolRtsps  = "rtsps"
+	ProtocolSrt    = "srt"
+	ProtocolRtmp   = "rtmp"
+)
+
+// Publish protocols of the publish session urls
+const (
+	ProtocolWhip = "whip" // WebRTC WHIP endpoint
+)
+
+// Protocols lists every playback protocol
+var Protocols = []string{
+	ProtocolWhep,
+	ProtocolWebRtc,
+	ProtocolHls,
+	ProtocolLlHls,
+	ProtocolRtsp,
+	ProtocolRtsps,
+	ProtocolSrt,
+	ProtocolRtmp,
+}
+
+// MediaBackend is the media server which serves the dynamic stream paths
+type MediaBackend interface {
+	Info(ctx context.Context) (*MediaInfo, error)
+	CreatePath(ctx context.Context, name, source string) error
+	CreateCommandPath(ctx context.Context, name, command string) error // Path published by command, restarted while the path exists
+	DeletePath(ctx context.Context, name string) error
+	ListReaders(ctx context.Context) ([]Reader, error)
+	KickReader(ctx context.Context, reader Reader) error
+	PathStatus(ctx context.Context, name string) (*PathStatus, error)                                // pkg.ErrNotFound when the path does not exist
+	SetRecord(ctx context.Context, name string, options *RecordOptions) error                        // Nil options stop the recording
+	RecordSegments(ctx context.Context, name string, options RecordOptions) ([]RecordSegment, error) // Oldest first
+	ListRecordSpans(ctx context.Context, name string, start, end time.Time) ([]RecordSpan, error)
+	DeleteRecordSegment(ctx context.Context, name string, start time.Time) error // pkg.ErrNotFound when the segment does not exist
+	BuildPlaybackURL(name string) string
+	BuildPlaybackURLs(name string) map[string]string                                           // By protocol, only the protocols served
+	BuildPublishURLs(name, credential string) map[string]string                                // By protocol, empty if publishing is not supported
+	BuildRecordURL(name string, start time.Time, duration time.Duration, format string) string // Empty if playback is not served
+}
@@ -0,0 +1,20 @@
+package domain
+
+// Media node states
+const (
+	NodeActive   = "active"   // Takes new stream sessions
+	NodeDraining = "draining" // Takes no new stream session
+	NodeRemoved  = "removed"  // Drained and empty, out of the pool
+)
+
+type Node struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+}
+
+type NodeRepository interface {
+	Close()
+	GetAll() ([]*Node, error)
+	FindByName(name string) *Node
+	Save(node *Node) error
+}
@@ -0,0 +1,8 @@
+package domain
+
+// OriginRepository counts the stream sessions relaying an origin path
+type OriginRepository interface {
+	Close()
+	Acquire(node, streamId string) (int64, error)
+	Release(node, streamId string) (int64, error)
+}
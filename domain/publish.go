@@ -0,0 +1,24 @@
+package domain
+
+import "time"
+
+// Publish is a dynamic path a publisher pushes a stream to
+type Publish struct {
+	Id          string    `json:"id"` // Stream id of the publisher
+	Uuid        string    `json:"uuid"`
+	Owner       string    `json:"owner"`
+	Instance    string    `json:"instance"`
+	Node        string    `json:"node"`
+	Credential  string    `json:"credential"` // One-time publish secret
+	CreatedAt   time.Time `json:"created_at"`
+	ConnectedAt time.Time `json:"connected_at"` // Zero value until the publisher is seen
+}
+
+type PublishRepository interface {
+	Close()
+	GetAll() ([]*Publish, error)
+	FindByUuid(uuid string) *Publish
+	Insert(publish *Publish) error
+	Delete(uuid string) error
+	Consume(uuid, address string) (bool, error) // False if the credential was already used by another address
+}
@@ -0,0 +1,99 @@
+package domain
+
+import (
+	"slices"
+	"time"
+)
+
+// Recording formats of the media server
+const (
+	RecordFormatFmp4   = "fmp4"
+	RecordFormatMpegTs = "mpegts"
+)
+
+// RecordOptions configures the recording of a path
+type RecordOptions struct {
+	Format          string
+	SegmentDuration time.Duration
+	Path            string // Storage path pattern, e.g. ./recordings/%path/%Y-%m-%d_%H-%M-%S-%f
+}
+
+// RecordSegment is a recorded file of a path
+type RecordSegment struct {
+	Start time.Time
+	File  string
+}
+
+// AlarmOwner owns the recordings triggered by alarm events, their viewers see them
+const AlarmOwner = "alarm"
+
+// RecordSpan is a continuous recorded span of a path
+type RecordSpan struct {
+	Recording string // Id of the recording
+	Tags      []string
+	Start     time.Time
+	Duration  time.Duration
+}
+
+// Recording is a recording of a stream session requested by a user
+type Recording struct {
+	Id              string     `json:"id"`
+	Owner           string     `json:"owner"`
+	StreamId        string     `json:"stream_id"`
+	Stream          string     `json:"stream"` // Uuid of the stream session
+	Node            string     `json:"node"`   // Media node recording the path
+	Path            string     `json:"path"`   // Recorded path, the session one or its origin one
+	Source          string     `json:"source"` // Camera url of the recorded path
+	Format          string     `json:"format"`
+	SegmentDuration uint       `json:"segment_duration"` // Seconds
+	PathPattern     string     `json:"path_pattern"`
+	StartedAt       time.Time  `json:"started_at"`
+	EndedAt         time.Time  `json:"ended_at"`  // Zero value while recording
+	Files           []string   `json:"files"`     // Segment files, set when the recording ends
+	Tags            []string   `json:"tags"`      // Matched by the retention policies
+	Hold            *LegalHold `json:"hold"`      // Kept forever while set
+	PurgedAt        time.Time  `json:"purged_at"` // Every segment deleted by the retention
+	StopAt          time.Time  `json:"stop_at"`   // Alarm recordings stop on their own, they hold an origin reference
+	Window          bool       `json:"window"`    // The path is recorded by others, only the window is kept
+	Viewers         []string   `json:"viewers"`   // Users who see the recording besides its owner
+}
+
+// VisibleTo reports whether a user may read the recording
+func (r *Recording) VisibleTo(username string) bool {
+	return r.Owner == username || slices.Contains(r.Viewers, username)
+}
+
+// LegalHold keeps a recording out of the retention
+type LegalHold struct {
+	Reason    string    `json:"reason"`
+	Principal string    `json:"principal"`
+	At        time.Time `json:"at"`
+}
+
+// RetentionReport sums up a run of the retention worker
+type RetentionReport struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Recordings int       `json:"recordings"` // Recordings checked
+	Held       int       `json:"held"`       // Recordings skipped under legal hold
+	Segments   int       `json:"segments"`   // Segments deleted
+	Bytes      int64     `json:"bytes"`      // Reclaimed, counted when the segment files are readable
+	Errors     []string  `json:"errors"`
+}
+
+// Active reports whether the recording is still running
+func (r *Recording) Active() bool {
+	return r.EndedAt.IsZero()
+}
+
+type RecordingRepository interface {
+	Close()
+	GetAll() ([]*Recording, error)
+	FindById(id string) *Recording
+	Insert(recording *Recording) error
+	Delete(id string) error
+	SaveReport(report *RetentionReport) error
+	LastReport() *RetentionReport
+	ClaimStop(id string) (bool, error)
+	ReleaseStop(id string) error
+}
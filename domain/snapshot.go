@@ -0,0 +1,18 @@
+package domain
+
+import "time"
+
+// Snapshot is a JPEG of the current frame of a camera
+type Snapshot struct {
+	StreamId string    `json:"stream_id"`
+	Width    uint      `json:"width"`  // 0 keeps the aspect ratio
+	Height   uint      `json:"height"` // 0 keeps the aspect ratio
+	Image    []byte    `json:"image"`
+	TakenAt  time.Time `json:"taken_at"`
+}
+
+type SnapshotRepository interface {
+	Close()
+	Find(streamId string, width, height uint) *Snapshot
+	Save(snapshot *Snapshot, ttl time.Duration) error // Served from the cache until ttl
+}
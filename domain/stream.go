@@ -1,8 +1,24 @@
 package domain
 
+import "time"
+
 type Stream struct {
-	Id   string `json:"id"`
-	Uuid string `json:"uuid"`
+	Id        string    `json:"id"`
+	Uuid      string    `json:"uuid"`
+	Owner     string    `json:"owner"`    // Username which started the stream
+	Instance  string    `json:"instance"` // Instance which created the stream path
+	Node      string    `json:"node"`     // Media node serving the stream path
+	Source    string    `json:"source"`   // Source of the stream path, used to re-create it
+	Origin    string    `json:"origin"`   // Origin node relaying the camera, empty if pulled directly
+	Profile   string    `json:"profile"`  // Transcoding profile, empty streams the camera
+	Token     string    `json:"token"`    // Secret of the proxy urls
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"` // Zero value never expires
+}
+
+// Expired reports whether the stream session is expired at t
+func (s *Stream) Expired(t time.Time) bool {
+	return !s.ExpiresAt.IsZero() && t.After(s.ExpiresAt)
 }
 
 type StreamRepository interface {
@@ -0,0 +1,25 @@
+package domain
+
+import "time"
+
+// Transcoder republishes the origin path of a camera with a transcoding
+// profile, shared by the stream sessions of the profile
+type Transcoder struct {
+	Node      string    `json:"node"` // Origin node running the transcoder
+	Path      string    `json:"path"`
+	StreamId  string    `json:"stream_id"`
+	Profile   string    `json:"profile"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TranscoderRepository stores the transcoders and counts the stream
+// sessions reading them
+type TranscoderRepository interface {
+	Close()
+	GetAll() ([]*Transcoder, error)
+	FindByPath(node, path string) *Transcoder
+	Insert(transcoder *Transcoder) error
+	Delete(node, path string) error
+	Acquire(node, path string) (int64, error)
+	Release(node, path string) (int64, error)
+}
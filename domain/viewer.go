@@ -0,0 +1,21 @@
+package domain
+
+import "time"
+
+// Viewer is a client reading a stream session through a proxy
+type Viewer struct {
+	Id       string    `json:"id"`
+	Stream   string    `json:"stream"` // Uuid of the stream session
+	Owner    string    `json:"owner"`
+	Node     string    `json:"node"`
+	Protocol string    `json:"protocol"`
+	Address  string    `json:"address"`
+	SeenAt   time.Time `json:"seen_at"`
+}
+
+type ViewerRepository interface {
+	Close()
+	GetAll() ([]*Viewer, error)
+	Save(viewer *Viewer, ttl time.Duration) error // Expires after ttl without activity
+	Delete(stream, id string) error
+}
@@ -0,0 +1,22 @@
+package dto
+
+type Go2RtcInfo struct {
+	Version string `json:"version"`
+	Pid     int    `json:"pid"`
+}
+
+type Go2RtcConnection struct {
+	Id         uint32   `json:"id"`
+	FormatName string   `json:"format_name"`
+	Protocol   string   `json:"protocol"`
+	RemoteAddr string   `json:"remote_addr"`
+	Url        string   `json:"url"`
+	Medias     []string `json:"medias"`
+	BytesRecv  uint64   `json:"bytes_recv"`
+	BytesSend  uint64   `json:"bytes_send"`
+}
+
+type Go2RtcStream struct {
+	Producers []Go2RtcConnection `json:"producers"`
+	Consumers []Go2RtcConnection `json:"consumers"`
+}
@@ -0,0 +1,49 @@
+package dto
+
+type MediaServerInfo struct {
+	Version string `json:"version"`
+	Started string `json:"started"`
+}
+
+type PathSource struct {
+	Type string `json:"type"`
+	Id   string `json:"id"`
+}
+
+type PathReader struct {
+	Type string `json:"type"`
+	Id   string `json:"id"`
+}
+
+type Path struct {
+	Name          string       `json:"name"`
+	ConfName      string       `json:"confName"`
+	Source        *PathSource  `json:"source"`
+	Ready         bool         `json:"ready"`
+	ReadyTime     *string      `json:"readyTime"`
+	Tracks        []string     `json:"tracks"`
+	BytesReceived uint64       `json:"bytesReceived"`
+	BytesSent     uint64       `json:"bytesSent"`
+	Readers       []PathReader `json:"readers"`
+}
+
+type PathList struct {
+	ItemCount int    `json:"itemCount"`
+	PageCount int    `json:"pageCount"`
+	Items     []Path `json:"items"`
+}
+
+type RecordingSegment struct {
+	Start string `json:"start"`
+}
+
+type Recording struct {
+	Name     string             `json:"name"`
+	Segments []RecordingSegment `json:"segments"`
+}
+
+type PlaybackSpan struct {
+	Start    string  `json:"start"`
+	Duration float64 `json:"duration"`
+	Url      string  `json:"url"`
+}
@@ -1,12 +1,18 @@
 package config
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"runtime"
 	"strconv"
 	"stream-session-api/internal/conf/network"
+	"stream-session-api/internal/evidence"
 	"stream-session-api/pkg"
+	"strings"
 
 	"gopkg.in/ini.v1"
 )
@@ -36,24 +42,137 @@ func setDefault() {
 
 	conf := network.Get()
 
-	// MediaMtx: http, rtsp, webrtc server
-	conf.MediaMtx.Http.Ip = os.Getenv("DEFAULT_MEDIAMTX_HTTP_SERVER_URI")
+	// Media backend
+	conf.Media = os.Getenv("DEFAULT_MEDIA_BACKEND")
+
+	// MediaMtx: default node with http, rtsp, webrtc server
+	node := network.MediaMtx{Name: "default", Weight: 1, Role: network.RoleEdge}
+	node.Http.Ip = os.Getenv("DEFAULT_MEDIAMTX_HTTP_SERVER_URI")
 	port, _ := strconv.ParseInt(os.Getenv("DEFAULT_MEDIAMTX_HTTP_SERVER_PORT"), 10, 16)
-	conf.MediaMtx.Http.Port = uint16(port)
+	node.Http.Port = uint16(port)
 
-	conf.MediaMtx.Rtsp.Path = os.Getenv("DEFAULT_MEDIAMTX_RTSP_SERVER_PATH")
-	conf.MediaMtx.Rtsp.Ip = os.Getenv("DEFAULT_MEDIAMTX_RTSP_SERVER_URI")
+	node.Rtsp.Path = os.Getenv("DEFAULT_MEDIAMTX_RTSP_SERVER_PATH")
+	node.Rtsp.Ip = os.Getenv("DEFAULT_MEDIAMTX_RTSP_SERVER_URI")
 	port, _ = strconv.ParseInt(os.Getenv("DEFAULT_MEDIAMTX_RTSP_SERVER_PORT"), 10, 16)
-	conf.MediaMtx.Rtsp.Port = uint16(port)
+	node.Rtsp.Port = uint16(port)
 
-	conf.MediaMtx.WebRtc.Ip = os.Getenv("DEFAULT_MEDIAMTX_WEBRTC_SERVER_URI")
+	node.WebRtc.Ip = os.Getenv("DEFAULT_MEDIAMTX_WEBRTC_SERVER_URI")
 	port, _ = strconv.ParseInt(os.Getenv("DEFAULT_MEDIAMTX_WEBRTC_SERVER_PORT"), 10, 16)
-	conf.MediaMtx.WebRtc.Port = uint16(port)
+	node.WebRtc.Port = uint16(port)
+
+	// Optional playback servers, disabled without port
+	node.Hls.Ip = os.Getenv("DEFAULT_MEDIAMTX_HLS_SERVER_URI")
+	port, _ = strconv.ParseInt(os.Getenv("DEFAULT_MEDIAMTX_HLS_SERVER_PORT"), 10, 32)
+	node.Hls.Port = uint16(port)
+
+	node.LlHls.Ip = os.Getenv("DEFAULT_MEDIAMTX_LLHLS_SERVER_URI")
+	port, _ = strconv.ParseInt(os.Getenv("DEFAULT_MEDIAMTX_LLHLS_SERVER_PORT"), 10, 32)
+	node.LlHls.Port = uint16(port)
+
+	node.Rtsps.Ip = os.Getenv("DEFAULT_MEDIAMTX_RTSPS_SERVER_URI")
+	port, _ = strconv.ParseInt(os.Getenv("DEFAULT_MEDIAMTX_RTSPS_SERVER_PORT"), 10, 32)
+	node.Rtsps.Port = uint16(port)
+
+	node.Srt.Ip = os.Getenv("DEFAULT_MEDIAMTX_SRT_SERVER_URI")
+	port, _ = strconv.ParseInt(os.Getenv("DEFAULT_MEDIAMTX_SRT_SERVER_PORT"), 10, 32)
+	node.Srt.Port = uint16(port)
+
+	node.Rtmp.Ip = os.Getenv("DEFAULT_MEDIAMTX_RTMP_SERVER_URI")
+	port, _ = strconv.ParseInt(os.Getenv("DEFAULT_MEDIAMTX_RTMP_SERVER_PORT"), 10, 32)
+	node.Rtmp.Port = uint16(port)
+
+	// Playback server of the recordings, disabled without port
+	node.Playback.Ip = os.Getenv("DEFAULT_MEDIAMTX_PLAYBACK_SERVER_URI")
+	port, _ = strconv.ParseInt(os.Getenv("DEFAULT_MEDIAMTX_PLAYBACK_SERVER_PORT"), 10, 32)
+	node.Playback.Port = uint16(port)
+
+	conf.MediaMtx = []network.MediaMtx{node}
+	conf.Placement = os.Getenv("DEFAULT_MEDIAMTX_PLACEMENT")
+
+	// Go2rtc: http api server
+	conf.Go2Rtc.Http.Ip = os.Getenv("DEFAULT_GO2RTC_HTTP_SERVER_URI")
+	port, _ = strconv.ParseInt(os.Getenv("DEFAULT_GO2RTC_HTTP_SERVER_PORT"), 10, 16)
+	conf.Go2Rtc.Http.Port = uint16(port)
+
+	// Embedded: rtsp server
+	conf.Embedded.Ip = os.Getenv("DEFAULT_EMBEDDED_RTSP_SERVER_URI")
+	port, _ = strconv.ParseInt(os.Getenv("DEFAULT_EMBEDDED_RTSP_SERVER_PORT"), 10, 16)
+	conf.Embedded.Port = uint16(port)
 
 	// Grpc
 	conf.Grpc.Ip = os.Getenv("DEFAULT_GRPC_SERVER_URI")
 	port, _ = strconv.ParseInt(os.Getenv("DEFAULT_GRPC_SERVER_PORT"), 10, 16)
 	conf.Grpc.Port = uint16(port)
+	conf.Grpc.AdminToken = os.Getenv("DEFAULT_GRPC_ADMIN_TOKEN")
+	conf.Grpc.TrustedProxies = nil
+	if proxies := os.Getenv("DEFAULT_GRPC_TRUSTED_PROXIES"); proxies != "" {
+		conf.Grpc.TrustedProxies = strings.Split(proxies, ",")
+	}
+
+	// Http
+	conf.Http.Ip = os.Getenv("DEFAULT_HTTP_SERVER_URI")
+	port, _ = strconv.ParseInt(os.Getenv("DEFAULT_HTTP_SERVER_PORT"), 10, 32)
+	conf.Http.Port = uint16(port)
+	conf.Http.PublicUrl = os.Getenv("DEFAULT_HTTP_PUBLIC_URL")
+	conf.Http.Whep, _ = strconv.ParseBool(os.Getenv("DEFAULT_HTTP_WHEP_PROXY"))
+	conf.Http.Hls, _ = strconv.ParseBool(os.Getenv("DEFAULT_HTTP_HLS_PROXY"))
+	conf.Http.TrustedProxies = nil
+	if proxies := os.Getenv("DEFAULT_HTTP_TRUSTED_PROXIES"); proxies != "" {
+		conf.Http.TrustedProxies = strings.Split(proxies, ",")
+	}
+
+	// Ice servers
+	if urls := os.Getenv("DEFAULT_ICE_STUN_URLS"); urls != "" {
+		conf.Ice.StunUrls = strings.Split(urls, ",")
+	}
+	if urls := os.Getenv("DEFAULT_ICE_TURN_URLS"); urls != "" {
+		conf.Ice.TurnUrls = strings.Split(urls, ",")
+	}
+	conf.Ice.TurnSecret = os.Getenv("DEFAULT_ICE_TURN_SECRET")
+	ttl, _ := strconv.ParseUint(os.Getenv("DEFAULT_ICE_TURN_TTL"), 10, 32)
+	conf.Ice.TurnTtl = uint(ttl)
+
+	// Recording
+	conf.Recording.Format = os.Getenv("DEFAULT_RECORDING_FORMAT")
+	duration, _ := strconv.ParseUint(os.Getenv("DEFAULT_RECORDING_SEGMENT_DURATION"), 10, 32)
+	conf.Recording.SegmentDuration = uint(duration)
+	conf.Recording.Path = os.Getenv("DEFAULT_RECORDING_PATH")
+	conf.Recording.Secret = os.Getenv("DEFAULT_RECORDING_URL_SECRET")
+	if conf.Recording.Secret == "" {
+		conf.Recording.Secret = newSecret()
+	}
+	ttl, _ = strconv.ParseUint(os.Getenv("DEFAULT_RECORDING_URL_TTL"), 10, 32)
+	conf.Recording.UrlTtl = uint(ttl)
+	conf.Recording.ExportPath = os.Getenv("DEFAULT_RECORDING_EXPORT_PATH")
+	ttl, _ = strconv.ParseUint(os.Getenv("DEFAULT_RECORDING_EXPORT_TTL"), 10, 32)
+	conf.Recording.ExportTtl = uint(ttl)
+	conf.Recording.SigningKey = os.Getenv("DEFAULT_RECORDING_SIGNING_KEY")
+	if conf.Recording.SigningKey == "" {
+		conf.Recording.SigningKey = newSigningKey()
+	}
+
+	// Retention of the recordings matching no policy
+	days, _ := strconv.ParseUint(os.Getenv("DEFAULT_RETENTION_DAYS"), 10, 32)
+	conf.Retention.DefaultDays = uint(days)
+
+	// Alarm webhook
+	conf.Alarms.Token = os.Getenv("DEFAULT_ALARM_TOKEN")
+	roll, _ := strconv.ParseUint(os.Getenv("DEFAULT_ALARM_PRE_ROLL"), 10, 32)
+	conf.Alarms.PreRoll = uint(roll)
+	roll, _ = strconv.ParseUint(os.Getenv("DEFAULT_ALARM_POST_ROLL"), 10, 32)
+	conf.Alarms.PostRoll = uint(roll)
+
+	// Snapshots
+	conf.Snapshot.Ffmpeg = os.Getenv("DEFAULT_SNAPSHOT_FFMPEG")
+	ttl, _ = strconv.ParseUint(os.Getenv("DEFAULT_SNAPSHOT_CACHE_TTL"), 10, 32)
+	conf.Snapshot.CacheTtl = uint(ttl)
+	ttl, _ = strconv.ParseUint(os.Getenv("DEFAULT_SNAPSHOT_TIMEOUT"), 10, 32)
+	conf.Snapshot.Timeout = uint(ttl)
+	ttl, _ = strconv.ParseUint(os.Getenv("DEFAULT_SNAPSHOT_URL_TTL"), 10, 32)
+	conf.Snapshot.UrlTtl = uint(ttl)
+
+	// Transcoding
+	conf.Transcoding.Ffmpeg = os.Getenv("DEFAULT_TRANSCODING_FFMPEG")
 
 	// Redis
 	conf.Redis.Ip = os.Getenv("DEFAULT_REDIS_SERVER_URI")
@@ -98,6 +217,17 @@ func show() error {
 
 		// Iterate over all keys in the current section
 		for _, key := range section.Keys() {
+			// Recording keys sign urls and evidence, the tokens authenticate
+			// webhooks and admin rpcs, the turn secret mints TURN credentials,
+			// keep them and the redis password out of the logs
+			if section.Name() == "recording" && (key.Name() == "secret" || key.Name() == "signing_key") ||
+				section.Name() == "alarms" && key.Name() == "token" ||
+				section.Name() == "grpc" && key.Name() == "admin_token" ||
+				section.Name() == "ice" && key.Name() == "turn_secret" ||
+				section.Name() == "redis" && key.Name() == "password" {
+				pkg.LogInfo(fmt.Sprintf(" %s = ***", key.Name()))
+				continue
+			}
 			pkg.LogInfo(fmt.Sprintf(" %s = %s", key.Name(), key.Value()))
 		}
 	}
@@ -129,8 +259,18 @@ func write() error {
 	// Get config instance
 	conf := network.Get()
 
+	// Media backend section
+	sec, err := settings.NewSection("media")
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("backend", conf.Media)
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+
 	// Grpc server section
-	sec, err := settings.NewSection("grpc")
+	sec, err = settings.NewSection("grpc")
 	if err != nil {
 		return pkg.NewError(pkg.ErrWriteFile, err)
 	}
@@ -142,53 +282,352 @@ func write() error {
 	if err != nil {
 		return pkg.NewError(pkg.ErrWriteFile, err)
 	}
+	_, err = sec.NewKey("admin_token", conf.Grpc.AdminToken)
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("trusted_proxies", strings.Join(conf.Grpc.TrustedProxies, ","))
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+
+	// Http server section
+	sec, err = settings.NewSection("http")
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("ip", conf.Http.Ip)
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("port", strconv.FormatUint(uint64(conf.Http.Port), 10))
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("public_url", conf.Http.PublicUrl)
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("whep", strconv.FormatBool(conf.Http.Whep))
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("hls", strconv.FormatBool(conf.Http.Hls))
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("trusted_proxies", strings.Join(conf.Http.TrustedProxies, ","))
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+
+	// Ice servers section
+	sec, err = settings.NewSection("ice")
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("stun_urls", strings.Join(conf.Ice.StunUrls, ","))
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("turn_urls", strings.Join(conf.Ice.TurnUrls, ","))
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("turn_secret", conf.Ice.TurnSecret)
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("turn_ttl", strconv.FormatUint(uint64(conf.Ice.TurnTtl), 10))
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+
+	// Recording section
+	sec, err = settings.NewSection("recording")
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("format", conf.Recording.Format)
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("segment_duration", strconv.FormatUint(uint64(conf.Recording.SegmentDuration), 10))
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("path", conf.Recording.Path)
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("secret", conf.Recording.Secret)
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("url_ttl", strconv.FormatUint(uint64(conf.Recording.UrlTtl), 10))
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("export_path", conf.Recording.ExportPath)
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("export_ttl", strconv.FormatUint(uint64(conf.Recording.ExportTtl), 10))
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("signing_key", conf.Recording.SigningKey)
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
 
-	// Mediamtx http server section
-	sec, err = settings.NewSection("mediamtx.http")
+	// Mediamtx node pool section
+	var names []string
+	for _, node := range conf.MediaMtx[1:] {
+		names = append(names, node.Name)
+	}
+	sec, err = settings.NewSection("mediamtx")
 	if err != nil {
 		return pkg.NewError(pkg.ErrWriteFile, err)
 	}
-	_, err = sec.NewKey("ip", conf.MediaMtx.Http.Ip)
+	_, err = sec.NewKey("placement", conf.Placement)
 	if err != nil {
 		return pkg.NewError(pkg.ErrWriteFile, err)
 	}
-	_, err = sec.NewKey("port", strconv.FormatUint(uint64(conf.MediaMtx.Http.Port), 10))
+	_, err = sec.NewKey("nodes", strings.Join(names, ","))
 	if err != nil {
 		return pkg.NewError(pkg.ErrWriteFile, err)
 	}
 
-	// Mediamtx rtsp server section
-	sec, err = settings.NewSection("mediamtx.rtsp")
+	// Mediamtx node sections, the default node keeps the "mediamtx" prefix
+	for i, node := range conf.MediaMtx {
+		prefix := "mediamtx"
+		if i > 0 {
+			prefix = "mediamtx." + node.Name
+		}
+		if err := writeNode(settings, prefix, node); err != nil {
+			return err
+		}
+	}
+
+	// Go2rtc http server section
+	sec, err = settings.NewSection("go2rtc.http")
 	if err != nil {
 		return pkg.NewError(pkg.ErrWriteFile, err)
 	}
-	_, err = sec.NewKey("ip", conf.MediaMtx.Rtsp.Ip)
+	_, err = sec.NewKey("ip", conf.Go2Rtc.Http.Ip)
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("port", strconv.FormatUint(uint64(conf.Go2Rtc.Http.Port), 10))
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+
+	// Embedded rtsp server section
+	sec, err = settings.NewSection("embedded.rtsp")
 	if err != nil {
 		return pkg.NewError(pkg.ErrWriteFile, err)
 	}
-	_, err = sec.NewKey("port", strconv.FormatUint(uint64(conf.MediaMtx.Rtsp.Port), 10))
+	_, err = sec.NewKey("ip", conf.Embedded.Ip)
 	if err != nil {
 		return pkg.NewError(pkg.ErrWriteFile, err)
 	}
-	_, err = sec.NewKey("path", conf.MediaMtx.Rtsp.Path)
+	_, err = sec.NewKey("port", strconv.FormatUint(uint64(conf.Embedded.Port), 10))
 	if err != nil {
 		return pkg.NewError(pkg.ErrWriteFile, err)
 	}
 
-	// Mediammtx webrtc server section
-	sec, err = settings.NewSection("mediamtx.webrtc")
+	// Zones section
+	names = nil
+	for _, zone := range conf.Zones {
+		names = append(names, zone.Name)
+	}
+	sec, err = settings.NewSection("zones")
 	if err != nil {
 		return pkg.NewError(pkg.ErrWriteFile, err)
 	}
-	_, err = sec.NewKey("ip", conf.MediaMtx.WebRtc.Ip)
+	_, err = sec.NewKey("names", strings.Join(names, ","))
 	if err != nil {
 		return pkg.NewError(pkg.ErrWriteFile, err)
 	}
-	_, err = sec.NewKey("port", strconv.FormatUint(uint64(conf.MediaMtx.WebRtc.Port), 10))
+
+	// Zone sections
+	for _, zone := range conf.Zones {
+		sec, err = settings.NewSection("zone." + zone.Name)
+		if err != nil {
+			return pkg.NewError(pkg.ErrWriteFile, err)
+		}
+		_, err = sec.NewKey("cidrs", strings.Join(zone.Cidrs, ","))
+		if err != nil {
+			return pkg.NewError(pkg.ErrWriteFile, err)
+		}
+		_, err = sec.NewKey("host", zone.Host)
+		if err != nil {
+			return pkg.NewError(pkg.ErrWriteFile, err)
+		}
+		_, err = sec.NewKey("scheme", zone.Scheme)
+		if err != nil {
+			return pkg.NewError(pkg.ErrWriteFile, err)
+		}
+		_, err = sec.NewKey("port", strconv.FormatUint(uint64(zone.Port), 10))
+		if err != nil {
+			return pkg.NewError(pkg.ErrWriteFile, err)
+		}
+		_, err = sec.NewKey("path_prefix", zone.PathPrefix)
+		if err != nil {
+			return pkg.NewError(pkg.ErrWriteFile, err)
+		}
+	}
+
+	// Retention section
+	names = nil
+	for _, policy := range conf.Retention.Policies {
+		names = append(names, policy.Name)
+	}
+	sec, err = settings.NewSection("retention")
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("default_days", strconv.FormatUint(uint64(conf.Retention.DefaultDays), 10))
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("policies", strings.Join(names, ","))
 	if err != nil {
 		return pkg.NewError(pkg.ErrWriteFile, err)
 	}
 
+	// Retention policy sections
+	for _, policy := range conf.Retention.Policies {
+		sec, err = settings.NewSection("retention." + policy.Name)
+		if err != nil {
+			return pkg.NewError(pkg.ErrWriteFile, err)
+		}
+		_, err = sec.NewKey("sources", strings.Join(policy.Sources, ","))
+		if err != nil {
+			return pkg.NewError(pkg.ErrWriteFile, err)
+		}
+		_, err = sec.NewKey("tags", strings.Join(policy.Tags, ","))
+		if err != nil {
+			return pkg.NewError(pkg.ErrWriteFile, err)
+		}
+		_, err = sec.NewKey("days", strconv.FormatUint(uint64(policy.Days), 10))
+		if err != nil {
+			return pkg.NewError(pkg.ErrWriteFile, err)
+		}
+	}
+
+	// Alarms section
+	names = nil
+	for _, source := range conf.Alarms.Sources {
+		names = append(names, source.Name)
+	}
+	sec, err = settings.NewSection("alarms")
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("token", conf.Alarms.Token)
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("pre_roll", strconv.FormatUint(uint64(conf.Alarms.PreRoll), 10))
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("post_roll", strconv.FormatUint(uint64(conf.Alarms.PostRoll), 10))
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("sources", strings.Join(names, ","))
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+
+	// Alarm source sections
+	for _, source := range conf.Alarms.Sources {
+		sec, err = settings.NewSection("alarm." + source.Name)
+		if err != nil {
+			return pkg.NewError(pkg.ErrWriteFile, err)
+		}
+		_, err = sec.NewKey("stream_ids", strings.Join(source.StreamIds, ","))
+		if err != nil {
+			return pkg.NewError(pkg.ErrWriteFile, err)
+		}
+		_, err = sec.NewKey("viewers", strings.Join(source.Viewers, ","))
+		if err != nil {
+			return pkg.NewError(pkg.ErrWriteFile, err)
+		}
+	}
+
+	// Snapshot section
+	sec, err = settings.NewSection("snapshot")
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("ffmpeg", conf.Snapshot.Ffmpeg)
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("cache_ttl", strconv.FormatUint(uint64(conf.Snapshot.CacheTtl), 10))
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("timeout", strconv.FormatUint(uint64(conf.Snapshot.Timeout), 10))
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("url_ttl", strconv.FormatUint(uint64(conf.Snapshot.UrlTtl), 10))
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+
+	// Transcoding section
+	names = nil
+	for _, profile := range conf.Transcoding.Profiles {
+		names = append(names, profile.Name)
+	}
+	sec, err = settings.NewSection("transcoding")
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("ffmpeg", conf.Transcoding.Ffmpeg)
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("profiles", strings.Join(names, ","))
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+
+	// Transcoding profile sections
+	for _, profile := range conf.Transcoding.Profiles {
+		sec, err = settings.NewSection("transcoding." + profile.Name)
+		if err != nil {
+			return pkg.NewError(pkg.ErrWriteFile, err)
+		}
+		_, err = sec.NewKey("width", strconv.FormatUint(uint64(profile.Width), 10))
+		if err != nil {
+			return pkg.NewError(pkg.ErrWriteFile, err)
+		}
+		_, err = sec.NewKey("height", strconv.FormatUint(uint64(profile.Height), 10))
+		if err != nil {
+			return pkg.NewError(pkg.ErrWriteFile, err)
+		}
+		_, err = sec.NewKey("bitrate", strconv.FormatUint(uint64(profile.Bitrate), 10))
+		if err != nil {
+			return pkg.NewError(pkg.ErrWriteFile, err)
+		}
+		_, err = sec.NewKey("fps", strconv.FormatUint(uint64(profile.Fps), 10))
+		if err != nil {
+			return pkg.NewError(pkg.ErrWriteFile, err)
+		}
+		_, err = sec.NewKey("codec", profile.Codec)
+		if err != nil {
+			return pkg.NewError(pkg.ErrWriteFile, err)
+		}
+	}
+
 	// Redis
 	sec, err = settings.NewSection("redis")
 	if err != nil {
@@ -220,6 +659,170 @@ func write() error {
 	return nil
 }
 
+// writeNode writes the sections of a mediamtx node
+func writeNode(settings *ini.File, prefix string, node network.MediaMtx) error {
+	// Node section
+	sec, err := settings.NewSection(prefix + ".node")
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("weight", strconv.FormatUint(uint64(node.Weight), 10))
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("max_sessions", strconv.FormatUint(uint64(node.MaxSessions), 10))
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("max_bandwidth", strconv.FormatUint(node.MaxBandwidth, 10))
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("role", node.Role)
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("cameras", strings.Join(node.Cameras, ","))
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("source", node.Source)
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+
+	// Http server section
+	sec, err = settings.NewSection(prefix + ".http")
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("ip", node.Http.Ip)
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("port", strconv.FormatUint(uint64(node.Http.Port), 10))
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+
+	// Rtsp server section
+	sec, err = settings.NewSection(prefix + ".rtsp")
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("ip", node.Rtsp.Ip)
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("port", strconv.FormatUint(uint64(node.Rtsp.Port), 10))
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("path", node.Rtsp.Path)
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+
+	// Webrtc server section
+	sec, err = settings.NewSection(prefix + ".webrtc")
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("ip", node.WebRtc.Ip)
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("port", strconv.FormatUint(uint64(node.WebRtc.Port), 10))
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	_, err = sec.NewKey("ice_hosts", strings.Join(node.IceHosts, ","))
+	if err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+
+	// Optional playback server sections
+	conns := []struct {
+		name string
+		conn network.NetConn
+	}{
+		{"hls", node.Hls},
+		{"llhls", node.LlHls},
+		{"rtsps", node.Rtsps},
+		{"srt", node.Srt},
+		{"rtmp", node.Rtmp},
+		{"playback", node.Playback},
+	}
+	for _, c := range conns {
+		sec, err = settings.NewSection(prefix + "." + c.name)
+		if err != nil {
+			return pkg.NewError(pkg.ErrWriteFile, err)
+		}
+		_, err = sec.NewKey("ip", c.conn.Ip)
+		if err != nil {
+			return pkg.NewError(pkg.ErrWriteFile, err)
+		}
+		_, err = sec.NewKey("port", strconv.FormatUint(uint64(c.conn.Port), 10))
+		if err != nil {
+			return pkg.NewError(pkg.ErrWriteFile, err)
+		}
+	}
+
+	return nil
+}
+
+// readNode reads the sections of a mediamtx node
+func readNode(settings *ini.File, prefix, name string) network.MediaMtx {
+	node := network.MediaMtx{Name: name}
+
+	// Node section
+	section := settings.Section(prefix + ".node")
+	node.Weight = section.Key("weight").MustUint(1)
+	node.MaxSessions = section.Key("max_sessions").MustUint(0)
+	node.MaxBandwidth = section.Key("max_bandwidth").MustUint64(0)
+	node.Role = section.Key("role").MustString(network.RoleEdge)
+	node.Cameras = section.Key("cameras").Strings(",")
+	node.Source = section.Key("source").String()
+
+	// Http server section
+	section = settings.Section(prefix + ".http")
+	node.Http.Ip = section.Key("ip").String()
+	port, _ := section.Key("port").Uint64()
+	node.Http.Port = uint16(port)
+
+	// Rtsp server section
+	section = settings.Section(prefix + ".rtsp")
+	node.Rtsp.Ip = section.Key("ip").String()
+	port, _ = section.Key("port").Uint64()
+	node.Rtsp.Port = uint16(port)
+	node.Rtsp.Path = section.Key("path").String()
+
+	// Webrtc server section
+	section = settings.Section(prefix + ".webrtc")
+	node.WebRtc.Ip = section.Key("ip").String()
+	port, _ = section.Key("port").Uint64()
+	node.WebRtc.Port = uint16(port)
+	node.IceHosts = section.Key("ice_hosts").Strings(",")
+
+	// Optional playback server sections
+	conns := map[string]*network.NetConn{
+		"hls":      &node.Hls,
+		"llhls":    &node.LlHls,
+		"rtsps":    &node.Rtsps,
+		"srt":      &node.Srt,
+		"rtmp":     &node.Rtmp,
+		"playback": &node.Playback,
+	}
+	for name, conn := range conns {
+		section = settings.Section(prefix + "." + name)
+		conn.Ip = section.Key("ip").String()
+		port, _ = section.Key("port").Uint64()
+		conn.Port = uint16(port)
+	}
+
+	return node
+}
+
 func read() error {
 	pkg.LogInfo("read conf...")
 
@@ -243,29 +846,157 @@ func read() error {
 
 	// Get config instance
 	conf := network.Get()
+	generated := false
+
+	// Media backend section
+	section := settings.Section("media")
+	conf.Media = section.Key("backend").MustString("mediamtx")
 
 	// Grpc server section
-	section := settings.Section("grpc")
+	section = settings.Section("grpc")
 	conf.Grpc.Ip = section.Key("ip").String()
 	port, _ := section.Key("port").Uint64()
 	conf.Grpc.Port = uint16(port)
+	conf.Grpc.AdminToken = section.Key("admin_token").String()
+	conf.Grpc.TrustedProxies = section.Key("trusted_proxies").Strings(",")
+	conf.Grpc.Proxies, err = network.ParseCidrs(conf.Grpc.TrustedProxies)
+	if err != nil {
+		return pkg.NewError(pkg.ErrReadFile, fmt.Errorf("grpc trusted_proxies: %w", err))
+	}
 
-	// Mediamtx http server section
-	section = settings.Section("mediamtx.http")
-	conf.MediaMtx.Http.Ip = section.Key("ip").String()
+	// Http server section
+	section = settings.Section("http")
+	conf.Http.Ip = section.Key("ip").String()
 	port, _ = section.Key("port").Uint64()
-	conf.MediaMtx.Http.Port = uint16(port)
-	// Mediamtx rtsp server section
-	section = settings.Section("mediamtx.rtsp")
-	conf.MediaMtx.Rtsp.Ip = section.Key("ip").String()
+	conf.Http.Port = uint16(port)
+	conf.Http.PublicUrl = section.Key("public_url").String()
+	conf.Http.Whep = section.Key("whep").MustBool(false)
+	conf.Http.Hls = section.Key("hls").MustBool(false)
+	conf.Http.TrustedProxies = section.Key("trusted_proxies").Strings(",")
+	conf.Http.Proxies, err = network.ParseCidrs(conf.Http.TrustedProxies)
+	if err != nil {
+		return pkg.NewError(pkg.ErrReadFile, fmt.Errorf("http trusted_proxies: %w", err))
+	}
+
+	// Ice servers section
+	section = settings.Section("ice")
+	conf.Ice.StunUrls = section.Key("stun_urls").Strings(",")
+	conf.Ice.TurnUrls = section.Key("turn_urls").Strings(",")
+	conf.Ice.TurnSecret = section.Key("turn_secret").String()
+	conf.Ice.TurnTtl = section.Key("turn_ttl").MustUint(86400)
+
+	// Recording section
+	section = settings.Section("recording")
+	conf.Recording.Format = section.Key("format").MustString("fmp4")
+	conf.Recording.SegmentDuration = section.Key("segment_duration").MustUint(3600)
+	conf.Recording.Path = section.Key("path").MustString("./recordings/%path/%Y-%m-%d_%H-%M-%S-%f")
+	conf.Recording.Secret = section.Key("secret").String()
+	// A config written before the keys existed gets them
+	if conf.Recording.Secret == "" {
+		conf.Recording.Secret = newSecret()
+		generated = true
+	}
+	conf.Recording.UrlTtl = section.Key("url_ttl").MustUint(3600)
+	conf.Recording.ExportPath = section.Key("export_path").MustString("./exports")
+	conf.Recording.ExportTtl = section.Key("export_ttl").MustUint(86400)
+	conf.Recording.SigningKey = section.Key("signing_key").String()
+	if conf.Recording.SigningKey == "" {
+		conf.Recording.SigningKey = newSigningKey()
+		generated = true
+	}
+
+	// Mediamtx node pool section
+	section = settings.Section("mediamtx")
+	conf.Placement = section.Key("placement").String()
+	conf.MediaMtx = []network.MediaMtx{readNode(settings, "mediamtx", "default")}
+	for _, name := range section.Key("nodes").Strings(",") {
+		conf.MediaMtx = append(conf.MediaMtx, readNode(settings, "mediamtx."+name, name))
+	}
+
+	// Go2rtc http server section
+	section = settings.Section("go2rtc.http")
+	conf.Go2Rtc.Http.Ip = section.Key("ip").String()
 	port, _ = section.Key("port").Uint64()
-	conf.MediaMtx.Rtsp.Port = uint16(port)
-	conf.MediaMtx.Rtsp.Path = section.Key("path").String()
-	// Mediamtx webrtc server section
-	section = settings.Section("mediamtx.webrtc")
-	conf.MediaMtx.WebRtc.Ip = section.Key("ip").String()
+	conf.Go2Rtc.Http.Port = uint16(port)
+
+	// Embedded rtsp server section
+	section = settings.Section("embedded.rtsp")
+	conf.Embedded.Ip = section.Key("ip").String()
 	port, _ = section.Key("port").Uint64()
-	conf.MediaMtx.WebRtc.Port = uint16(port)
+	conf.Embedded.Port = uint16(port)
+
+	// Zone sections
+	conf.Zones = nil
+	for _, name := range settings.Section("zones").Key("names").Strings(",") {
+		section = settings.Section("zone." + name)
+		zone := network.Zone{Name: name}
+		zone.Cidrs = section.Key("cidrs").Strings(",")
+		zone.Networks, err = network.ParseCidrs(zone.Cidrs)
+		if err != nil {
+			return pkg.NewError(pkg.ErrReadFile, fmt.Errorf("zone %s cidrs: %w", name, err))
+		}
+		zone.Host = section.Key("host").String()
+		zone.Scheme = section.Key("scheme").String()
+		port, _ = section.Key("port").Uint64()
+		zone.Port = uint16(port)
+		zone.PathPrefix = section.Key("path_prefix").String()
+		conf.Zones = append(conf.Zones, zone)
+	}
+
+	// Retention sections
+	section = settings.Section("retention")
+	conf.Retention.DefaultDays = section.Key("default_days").MustUint(0)
+	conf.Retention.Policies = nil
+	for _, name := range section.Key("policies").Strings(",") {
+		section = settings.Section("retention." + name)
+		policy := network.RetentionPolicy{Name: name}
+		policy.Sources = section.Key("sources").Strings(",")
+		policy.Tags = section.Key("tags").Strings(",")
+		policy.Days = section.Key("days").MustUint(0)
+		conf.Retention.Policies = append(conf.Retention.Policies, policy)
+	}
+
+	// Alarm sections
+	section = settings.Section("alarms")
+	conf.Alarms.Token = section.Key("token").String()
+	conf.Alarms.PreRoll = section.Key("pre_roll").MustUint(10)
+	conf.Alarms.PostRoll = section.Key("post_roll").MustUint(30)
+	conf.Alarms.Sources = nil
+	for _, name := range section.Key("sources").Strings(",") {
+		section = settings.Section("alarm." + name)
+		source := network.AlarmSource{Name: name}
+		source.StreamIds = section.Key("stream_ids").Strings(",")
+		source.Viewers = section.Key("viewers").Strings(",")
+		conf.Alarms.Sources = append(conf.Alarms.Sources, source)
+	}
+
+	// Snapshot section
+	section = settings.Section("snapshot")
+	conf.Snapshot.Ffmpeg = section.Key("ffmpeg").MustString("ffmpeg")
+	conf.Snapshot.CacheTtl = section.Key("cache_ttl").MustUint(10)
+	conf.Snapshot.Timeout = section.Key("timeout").MustUint(10)
+	conf.Snapshot.UrlTtl = section.Key("url_ttl").MustUint(3600)
+
+	// Transcoding sections
+	section = settings.Section("transcoding")
+	conf.Transcoding.Ffmpeg = section.Key("ffmpeg").MustString("ffmpeg")
+	conf.Transcoding.Profiles = nil
+	for _, name := range section.Key("profiles").Strings(",") {
+		section = settings.Section("transcoding." + name)
+		profile := network.Profile{Name: name}
+		profile.Width = section.Key("width").MustUint(0)
+		profile.Height = section.Key("height").MustUint(0)
+		profile.Bitrate = section.Key("bitrate").MustUint(1000)
+		profile.Fps = section.Key("fps").MustUint(0)
+		profile.Codec = section.Key("codec").MustString("h264")
+		if err := profile.Validate(); err != nil {
+			return pkg.NewError(pkg.ErrReadFile, err)
+		}
+		if _, ok := conf.Transcoding.Profile(name); ok {
+			return pkg.NewError(pkg.ErrReadFile, fmt.Errorf("duplicate profile %s", name))
+		}
+		conf.Transcoding.Profiles = append(conf.Transcoding.Profiles, profile)
+	}
 
 	// Redis
 	section = settings.Section("redis")
@@ -279,6 +1010,11 @@ func read() error {
 	// Set net conf
 	network.Set(conf)
 
+	// Persist the generated secrets
+	if generated {
+		return write()
+	}
+
 	return nil
 }
 
@@ -320,5 +1056,26 @@ func Get() error {
 		return err
 	}
 
+	// Public key to verify the export manifests with
+	if key, err := evidence.PrivateKey(network.Get().Recording.SigningKey); err == nil {
+		pkg.LogInfo("export manifests signed with public key " + base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)))
+	} else {
+		pkg.LogWarn(fmt.Sprintf("invalid recording signing_key, clips cannot be exported: %v", err))
+	}
+
 	return nil
 }
+
+// newSecret returns a random secret signing the recording urls
+func newSecret() string {
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	return hex.EncodeToString(secret)
+}
+
+// newSigningKey returns a base64 Ed25519 seed signing the export manifests
+func newSigningKey() string {
+	seed := make([]byte, ed25519.SeedSize)
+	rand.Read(seed)
+	return base64.StdEncoding.EncodeToString(seed)
+}
@@ -1,5 +1,12 @@
 package network
 
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+)
+
 type NetConn struct {
 	Ip   string `json:"ip"`
 	Port uint16 `json:"port"`
@@ -12,9 +19,182 @@ type Rtsp struct {
 }
 
 type MediaMtx struct {
-	Http   NetConn `json:"http"`
-	Rtsp   Rtsp    `json:"rtsp"`
-	WebRtc NetConn `json:"webrtc"`
+	Name         string   `json:"name"`
+	Http         NetConn  `json:"http"`
+	Rtsp         Rtsp     `json:"rtsp"`
+	WebRtc       NetConn  `json:"webrtc"`
+	IceHosts     []string `json:"ice_hosts"` // Public addresses of the webrtc host candidates, empty keeps them
+	Hls          NetConn  `json:"hls"`       // Optional servers below are disabled without port
+	LlHls        NetConn  `json:"llhls"`     // Low-latency HLS server
+	Rtsps        NetConn  `json:"rtsps"`
+	Srt          NetConn  `json:"srt"`
+	Rtmp         NetConn  `json:"rtmp"`
+	Playback     NetConn  `json:"playback"`      // Playback server of the recordings
+	Weight       uint     `json:"weight"`        // Placement weight, higher takes more sessions
+	MaxSessions  uint     `json:"max_sessions"`  // 0 unlimited
+	MaxBandwidth uint64   `json:"max_bandwidth"` // Kbit/s sent to readers, 0 unlimited
+	Role         string   `json:"role"`          // edge or origin
+	Cameras      []string `json:"cameras"`       // Stream ids pulled by an origin node
+	Source       string   `json:"source"`        // Camera url pulled by origin paths, {id} is the stream id
+}
+
+// Node roles, see MediaMtx.Role
+const (
+	RoleEdge   = "edge"   // Serves viewers
+	RoleOrigin = "origin" // Pulls the cameras of its site for the edges
+)
+
+type Go2Rtc struct {
+	Http NetConn `json:"http"`
+}
+
+// Zone is the public view of the media nodes from a client network
+type Zone struct {
+	Name       string       `json:"name"`
+	Cidrs      []string     `json:"cidrs"`       // Client networks of the zone
+	Networks   []*net.IPNet `json:"-"`           // Cidrs parsed when the config is read
+	Host       string       `json:"host"`        // Public host, {node} is the media node name
+	Scheme     string       `json:"scheme"`      // Scheme of http urls, empty keeps it
+	Port       uint16       `json:"port"`        // Port of http urls, 0 keeps it
+	PathPrefix string       `json:"path_prefix"` // Path prefix of http urls, {node} is the media node name
+}
+
+type Grpc struct {
+	Ip             string       `json:"ip"`
+	Port           uint16       `json:"port"`
+	AdminToken     string       `json:"admin_token"`     // Bearer token of the AdminService rpcs, empty refuses them
+	TrustedProxies []string     `json:"trusted_proxies"` // Networks of the proxies whose x-forwarded-for is honored
+	Proxies        []*net.IPNet `json:"-"`               // TrustedProxies parsed when the config is read
+}
+
+// Trusted reports whether ip is a trusted proxy
+func (g Grpc) Trusted(ip net.IP) bool {
+	return Contains(g.Proxies, ip)
+}
+
+type Http struct {
+	Ip             string       `json:"ip"`
+	Port           uint16       `json:"port"`
+	PublicUrl      string       `json:"public_url"`      // Base of the urls served by the http server, default to http://ip:port
+	Whep           bool         `json:"whep"`            // Hand out the whep proxy url instead of the media node one
+	Hls            bool         `json:"hls"`             // Hand out the hls proxy url instead of the media node one
+	TrustedProxies []string     `json:"trusted_proxies"` // Networks of the proxies whose X-Forwarded-For is honored
+	Proxies        []*net.IPNet `json:"-"`               // TrustedProxies parsed when the config is read
+}
+
+// Trusted reports whether ip is a trusted proxy
+func (h Http) Trusted(ip net.IP) bool {
+	return Contains(h.Proxies, ip)
+}
+
+// BaseUrl returns the public base url of the http server
+func (h Http) BaseUrl() string {
+	if h.PublicUrl != "" {
+		return strings.TrimSuffix(h.PublicUrl, "/")
+	}
+	return fmt.Sprintf("http://%s:%d", h.Ip, h.Port)
+}
+
+type Ice struct {
+	StunUrls   []string `json:"stun_urls"`
+	TurnUrls   []string `json:"turn_urls"`
+	TurnSecret string   `json:"turn_secret"` // Shared secret of the coturn rest api (static-auth-secret)
+	TurnTtl    uint     `json:"turn_ttl"`    // Seconds, credential lifetime of sessions which never expire
+}
+
+type Recording struct {
+	Format          string `json:"format"`           // fmp4 or mpegts
+	SegmentDuration uint   `json:"segment_duration"` // Seconds
+	Path            string `json:"path"`             // MediaMTX recordPath pattern, without extension
+	Secret          string `json:"secret"`           // Signs the playback urls
+	UrlTtl          uint   `json:"url_ttl"`          // Seconds, playback url lifetime
+	ExportPath      string `json:"export_path"`      // Directory of the exported clips
+	ExportTtl       uint   `json:"export_ttl"`       // Seconds, exported clips are deleted after
+	SigningKey      string `json:"signing_key"`      // Base64 Ed25519 seed signing the export manifests
+}
+
+// RetentionPolicy keeps the recordings of matching sources or tags for days
+type RetentionPolicy struct {
+	Name    string   `json:"name"`
+	Sources []string `json:"sources"` // Stream id patterns, e.g. lobby-*
+	Tags    []string `json:"tags"`    // Recording tags
+	Days    uint     `json:"days"`    // 0 keeps forever
+}
+
+type Retention struct {
+	DefaultDays uint              `json:"default_days"` // Recordings matching no policy, 0 keeps forever
+	Policies    []RetentionPolicy `json:"policies"`     // The first matching wins
+}
+
+// AlarmSource maps the events of an alarm system source to the cameras
+type AlarmSource struct {
+	Name      string   `json:"name"`       // Source id of the events
+	StreamIds []string `json:"stream_ids"` // Cameras recorded on an event
+	Viewers   []string `json:"viewers"`    // Users who see the recordings of the events
+}
+
+type Alarms struct {
+	Token    string        `json:"token"`     // Bearer token of the webhook, empty refuses every event
+	PreRoll  uint          `json:"pre_roll"`  // Seconds recorded before the event
+	PostRoll uint          `json:"post_roll"` // Seconds recorded after the event
+	Sources  []AlarmSource `json:"sources"`
+}
+
+type Snapshot struct {
+	Ffmpeg   string `json:"ffmpeg"`    // Ffmpeg binary grabbing the frames
+	CacheTtl uint   `json:"cache_ttl"` // Seconds a snapshot is served from the cache
+	Timeout  uint   `json:"timeout"`   // Seconds, frame grab deadline
+	UrlTtl   uint   `json:"url_ttl"`   // Seconds, snapshot url lifetime
+}
+
+// Profile is a transcoding profile of the bandwidth-limited viewers
+type Profile struct {
+	Name    string `json:"name"`
+	Width   uint   `json:"width"`   // 0 keeps the aspect ratio
+	Height  uint   `json:"height"`  // 0 keeps the aspect ratio
+	Bitrate uint   `json:"bitrate"` // Kbit/s
+	Fps     uint   `json:"fps"`     // 0 keeps the camera frame rate
+	Codec   string `json:"codec"`   // h264, h265, vp8 or vp9
+}
+
+// ProfileSource streams the camera without transcoding
+const ProfileSource = "source"
+
+// Codecs are the video codecs of the transcoding profiles
+var Codecs = []string{"h264", "h265", "vp8", "vp9"}
+
+// Validate checks a profile can name a media path and be encoded
+func (p Profile) Validate() error {
+	if p.Name == "" || p.Name == ProfileSource {
+		return fmt.Errorf("invalid profile name %q", p.Name)
+	}
+	for _, c := range p.Name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return fmt.Errorf("invalid character %q in profile name %s", c, p.Name)
+		}
+	}
+	if !slices.Contains(Codecs, p.Codec) {
+		return fmt.Errorf("unknown codec %q of profile %s, expected one of %s", p.Codec, p.Name, strings.Join(Codecs, ", "))
+	}
+	if p.Bitrate == 0 {
+		return fmt.Errorf("zero bitrate of profile %s", p.Name)
+	}
+	return nil
+}
+
+type Transcoding struct {
+	Ffmpeg   string    `json:"ffmpeg"` // Ffmpeg binary on the media nodes
+	Profiles []Profile `json:"profiles"`
+}
+
+// Profile returns the transcoding profile by name
+func (t Transcoding) Profile(name string) (Profile, bool) {
+	for _, profile := range t.Profiles {
+		if profile.Name == name {
+			return profile, true
+		}
+	}
+	return Profile{}, false
 }
 
 type Redis struct {
@@ -25,7 +205,70 @@ type Redis struct {
 }
 
 type NetCfg struct {
-	MediaMtx MediaMtx `json:"mediamtx"`
-	Grpc     NetConn  `json:"grpc"`
-	Redis    Redis    `json:"redis"`
+	Media       string      `json:"media"`     // Media backend: mediamtx, go2rtc or embedded
+	Placement   string      `json:"placement"` // MediaMTX node placement: least-sessions, least-bandwidth or hash
+	MediaMtx    []MediaMtx  `json:"mediamtx"`  // MediaMTX node pool, the first one is the default node
+	Go2Rtc      Go2Rtc      `json:"go2rtc"`
+	Embedded    NetConn     `json:"embedded"` // Rtsp server of the embedded backend
+	Zones       []Zone      `json:"zones"`    // Url templates by client network, the first matching wins
+	Grpc        Grpc        `json:"grpc"`
+	Http        Http        `json:"http"` // Http server of the signaling and media proxies
+	Ice         Ice         `json:"ice"`  // Stun and turn servers handed to the players
+	Recording   Recording   `json:"recording"`
+	Retention   Retention   `json:"retention"`
+	Alarms      Alarms      `json:"alarms"`      // Event-triggered recording
+	Snapshot    Snapshot    `json:"snapshot"`    // Camera thumbnails
+	Transcoding Transcoding `json:"transcoding"` // Profiles of StartStream
+	Redis       Redis       `json:"redis"`
+}
+
+// Node returns the MediaMTX node by name, the default node if not found
+func (c NetCfg) Node(name string) MediaMtx {
+	for _, node := range c.MediaMtx {
+		if node.Name == name {
+			return node
+		}
+	}
+	if len(c.MediaMtx) == 0 {
+		return MediaMtx{}
+	}
+	return c.MediaMtx[0]
+}
+
+// Origin returns the origin node pulling the camera of streamId
+func (c NetCfg) Origin(streamId string) (MediaMtx, bool) {
+	for _, node := range c.MediaMtx {
+		if node.Role != RoleOrigin {
+			continue
+		}
+		for _, camera := range node.Cameras {
+			if camera == streamId {
+				return node, true
+			}
+		}
+	}
+	return MediaMtx{}, false
+}
+
+// ParseCidrs parses a list of networks
+func ParseCidrs(cidrs []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, ipNet)
+	}
+	return networks, nil
+}
+
+// Contains reports whether one of networks contains ip
+func Contains(networks []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range networks {
+		if ip != nil && ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
 }
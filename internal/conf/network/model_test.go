@@ -0,0 +1,33 @@
+package network
+
+import "testing"
+
+func TestProfileValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile Profile
+		valid   bool
+	}{
+		{name: "valid", profile: Profile{Name: "720p_low-1", Codec: "h264", Bitrate: 1500}, valid: true},
+		{name: "every codec", profile: Profile{Name: "vp9", Codec: "vp9", Bitrate: 800}, valid: true},
+		{name: "empty name", profile: Profile{Codec: "h264", Bitrate: 1500}},
+		{name: "source name", profile: Profile{Name: ProfileSource, Codec: "h264", Bitrate: 1500}},
+		{name: "path separator", profile: Profile{Name: "hd/low", Codec: "h264", Bitrate: 1500}},
+		{name: "space", profile: Profile{Name: "hd low", Codec: "h264", Bitrate: 1500}},
+		{name: "shell character", profile: Profile{Name: "hd;rm", Codec: "h264", Bitrate: 1500}},
+		{name: "unknown codec", profile: Profile{Name: "hd", Codec: "av1", Bitrate: 1500}},
+		{name: "empty codec", profile: Profile{Name: "hd", Bitrate: 1500}},
+		{name: "zero bitrate", profile: Profile{Name: "hd", Codec: "h264"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.profile.Validate()
+			if test.valid && err != nil {
+				t.Fatalf("Validate: %v", err)
+			}
+			if !test.valid && err == nil {
+				t.Fatal("Validate accepted an invalid profile")
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package evidence
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// Manifest describes an exported clip, signed to prove it was not modified
+type Manifest struct {
+	Version   int       `json:"version"`
+	ExportId  string    `json:"export_id"`
+	Recording string    `json:"recording_id"`
+	StreamId  string    `json:"stream_id"`
+	Source    string    `json:"source"` // Source url, credentials redacted
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Principal string    `json:"principal"` // User who requested the export
+	CreatedAt time.Time `json:"created_at"`
+	Files     []File    `json:"files"`
+	PublicKey string    `json:"public_key"` // Base64 Ed25519 public key of the signer
+	Signature string    `json:"signature"`  // Base64 Ed25519 signature of the manifest without it
+}
+
+// File is a file of an exported clip
+type File struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256"` // Hex
+}
+
+// PrivateKey decodes a base64 Ed25519 seed or private key
+func PrivateKey(encoded string) (ed25519.PrivateKey, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	switch len(key) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(key), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(key), nil
+	default:
+		return nil, fmt.Errorf("invalid ed25519 private key size %d", len(key))
+	}
+}
+
+// PublicKey decodes a base64 Ed25519 public key
+func PublicKey(encoded string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid ed25519 public key size %d", len(key))
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// Redact hides the credentials of a source url
+func Redact(source string) string {
+	u, err := url.Parse(source)
+	if err != nil {
+		return ""
+	}
+	return u.Redacted()
+}
+
+// HashFile returns the description of a file with its sha-256
+func HashFile(path string) (File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return File{}, err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return File{}, err
+	}
+
+	return File{Name: filepath.Base(path), Size: size, Sha256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// Sign sets the public key and the signature of a manifest
+func (m *Manifest) Sign(key ed25519.PrivateKey) error {
+	m.PublicKey = base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+	payload, err := m.payload()
+	if err != nil {
+		return err
+	}
+	m.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload))
+	return nil
+}
+
+// Verify checks the manifest is signed by key
+func (m *Manifest) Verify(key ed25519.PublicKey) error {
+	if m.PublicKey != base64.StdEncoding.EncodeToString(key) {
+		return fmt.Errorf("manifest signed by another key %s", m.PublicKey)
+	}
+	signature, err := base64.StdEncoding.DecodeString(m.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature: %v", err)
+	}
+	payload, err := m.payload()
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(key, payload, signature) {
+		return fmt.Errorf("signature mismatch, the manifest was modified")
+	}
+	return nil
+}
+
+// VerifyFile checks a file is listed with the same hash by the manifest
+func (m *Manifest) VerifyFile(path string) error {
+	file, err := HashFile(path)
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(m.Files, func(f File) bool { return f.Sha256 == file.Sha256 && f.Size == file.Size }) {
+		return fmt.Errorf("%s sha-256 %s not in the manifest, the clip was modified", file.Name, file.Sha256)
+	}
+	return nil
+}
+
+// Write saves the manifest as indented json
+func (m *Manifest) Write(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Read loads a manifest file
+func Read(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// payload returns the signed bytes, the manifest without its signature
+func (m *Manifest) payload() ([]byte, error) {
+	unsigned := *m
+	unsigned.Signature = ""
+	return json.Marshal(unsigned)
+}
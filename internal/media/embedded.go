@@ -0,0 +1,451 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"stream-session-api/domain"
+	"stream-session-api/internal/conf/network"
+	"stream-session-api/pkg"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4"
+	"github.com/bluenviron/gortsplib/v4/pkg/base"
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
+	"github.com/bluenviron/gortsplib/v4/pkg/format"
+	"github.com/pion/rtp"
+)
+
+const (
+	embeddedReadyTimeout   = 10 * time.Second // Wait for the source on describe
+	embeddedIdleTimeout    = 10 * time.Second // Stop pulling the source without reader
+	embeddedReconnectPause = 2 * time.Second
+)
+
+var (
+	embeddedInstance *embedded
+	embeddedErr      error // Failure to start the rtsp server
+	embeddedOnce     sync.Once
+)
+
+// embeddedPath pulls its source on demand and re-serves it
+type embeddedPath struct {
+	name   string
+	source string
+
+	mu      sync.Mutex
+	stream  *gortsplib.ServerStream
+	ready   chan struct{} // Closed once stream is set
+	cancel  context.CancelFunc
+	readers map[*gortsplib.ServerSession]struct{}
+}
+
+type embedded struct {
+	conf    network.NetConn
+	server  *gortsplib.Server
+	started time.Time
+
+	mu    sync.RWMutex
+	paths map[string]*embeddedPath
+}
+
+// NewEmbedded returns the in-process rtsp proxy, started by Init.
+// It needs no external media server, a path pulls its rtsp source while
+// it has readers and re-serves it on rtsp://ip:port/<name>.
+func NewEmbedded(conf network.NetConn) domain.MediaBackend {
+	startEmbedded(conf)
+	return embeddedInstance
+}
+
+// startEmbedded starts the rtsp server of the embedded backend once
+func startEmbedded(conf network.NetConn) error {
+	embeddedOnce.Do(func() {
+		e := &embedded{
+			conf:    conf,
+			started: time.Now(),
+			paths:   make(map[string]*embeddedPath),
+		}
+		e.server = &gortsplib.Server{
+			Handler:     e,
+			RTSPAddress: fmt.Sprintf("%s:%d", conf.Ip, conf.Port),
+		}
+		if err := e.server.Start(); err != nil {
+			embeddedErr = pkg.NewError(pkg.ErrUnavailable, fmt.Errorf("failed to start embedded rtsp server: %w", err))
+			return
+		}
+		pkg.LogInfo(fmt.Sprintf("embedded rtsp server listening on %s...", e.server.RTSPAddress))
+		embeddedInstance = e
+	})
+	return embeddedErr
+}
+
+// closeEmbedded stops the embedded rtsp server if it was started
+func closeEmbedded() {
+	if embeddedInstance == nil {
+		return
+	}
+
+	embeddedInstance.mu.Lock()
+	for _, path := range embeddedInstance.paths {
+		path.stop()
+	}
+	embeddedInstance.mu.Unlock()
+
+	embeddedInstance.server.Close()
+}
+
+func (e *embedded) Info(ctx context.Context) (*domain.MediaInfo, error) {
+	return &domain.MediaInfo{Version: "embedded", Started: e.started.Format(time.RFC3339)}, nil
+}
+
+func (e *embedded) CreatePath(ctx context.Context, name, source string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, ok := e.paths[name]; ok {
+		return pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("path %s already exists", name))
+	}
+	e.paths[name] = &embeddedPath{
+		name:    name,
+		source:  source,
+		ready:   make(chan struct{}),
+		readers: make(map[*gortsplib.ServerSession]struct{}),
+	}
+
+	return nil
+}
+
+func (e *embedded) CreateCommandPath(ctx context.Context, name, command string) error {
+	return pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("the embedded backend cannot run a path command"))
+}
+
+func (e *embedded) DeletePath(ctx context.Context, name string) error {
+	e.mu.Lock()
+	path, ok := e.paths[name]
+	delete(e.paths, name)
+	e.mu.Unlock()
+
+	if !ok {
+		return pkg.NewError(pkg.ErrNotFound, fmt.Errorf("path %s not found", name))
+	}
+
+	// Disconnect readers and stop pulling
+	path.mu.Lock()
+	for session := range path.readers {
+		session.Close()
+	}
+	path.mu.Unlock()
+	path.stop()
+
+	return nil
+}
+
+func (e *embedded) ListReaders(ctx context.Context) ([]domain.Reader, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var readers []domain.Reader
+	for _, path := range e.paths {
+		path.mu.Lock()
+		for session := range path.readers {
+			readers = append(readers, domain.Reader{
+				Id:        sessionId(session),
+				Type:      "rtsp",
+				Path:      path.name,
+				BytesSent: session.Stats().BytesSent,
+			})
+		}
+		path.mu.Unlock()
+	}
+
+	return readers, nil
+}
+
+func (e *embedded) KickReader(ctx context.Context, reader domain.Reader) error {
+	path := e.path(reader.Path)
+	if path == nil {
+		return pkg.NewError(pkg.ErrNotFound, fmt.Errorf("path %s not found", reader.Path))
+	}
+
+	path.mu.Lock()
+	defer path.mu.Unlock()
+	for session := range path.readers {
+		if sessionId(session) == reader.Id {
+			session.Close()
+			return nil
+		}
+	}
+
+	return pkg.NewError(pkg.ErrNotFound, fmt.Errorf("reader %s not found", reader.Id))
+}
+
+func (e *embedded) PathStatus(ctx context.Context, name string) (*domain.PathStatus, error) {
+	path := e.path(name)
+	if path == nil {
+		return nil, pkg.NewError(pkg.ErrNotFound, fmt.Errorf("path %s not found", name))
+	}
+
+	path.mu.Lock()
+	defer path.mu.Unlock()
+
+	status := &domain.PathStatus{
+		Name:    name,
+		Source:  "rtspSource",
+		Readers: len(path.readers),
+	}
+	if path.stream != nil {
+		status.Ready = true
+		status.BytesSent = path.stream.Stats().BytesSent
+		for _, media := range path.stream.Description().Medias {
+			for _, forma := range media.Formats {
+				status.Tracks = append(status.Tracks, forma.Codec())
+			}
+		}
+	}
+
+	return status, nil
+}
+
+func (e *embedded) BuildPlaybackURL(name string) string {
+	return fmt.Sprintf("rtsp://%s:%d/%s", e.conf.Ip, e.conf.Port, name)
+}
+
+func (e *embedded) BuildPlaybackURLs(name string) map[string]string {
+	return map[string]string{domain.ProtocolRtsp: e.BuildPlaybackURL(name)}
+}
+
+func (e *embedded) SetRecord(ctx context.Context, name string, options *domain.RecordOptions) error {
+	return pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("the embedded backend cannot record a path"))
+}
+
+func (e *embedded) RecordSegments(ctx context.Context, name string, options domain.RecordOptions) ([]domain.RecordSegment, error) {
+	return nil, pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("the embedded backend cannot record a path"))
+}
+
+func (e *embedded) ListRecordSpans(ctx context.Context, name string, start, end time.Time) ([]domain.RecordSpan, error) {
+	return nil, pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("the embedded backend cannot record a path"))
+}
+
+func (e *embedded) DeleteRecordSegment(ctx context.Context, name string, start time.Time) error {
+	return pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("the embedded backend cannot record a path"))
+}
+
+// BuildPublishURLs returns no url, publishing is not supported
+func (e *embedded) BuildPublishURLs(name, credential string) map[string]string {
+	return nil
+}
+
+// BuildRecordURL returns no url, recording is not supported
+func (e *embedded) BuildRecordURL(name string, start time.Time, duration time.Duration, format string) string {
+	return ""
+}
+
+// path finds a path by name
+func (e *embedded) path(name string) *embeddedPath {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.paths[strings.TrimPrefix(name, "/")]
+}
+
+// OnDescribe starts pulling the source and waits until it is ready
+func (e *embedded) OnDescribe(ctx *gortsplib.ServerHandlerOnDescribeCtx) (*base.Response, *gortsplib.ServerStream, error) {
+	path := e.path(ctx.Path)
+	if path == nil {
+		return &base.Response{StatusCode: base.StatusNotFound}, nil, nil
+	}
+
+	ready := path.start()
+	select {
+	case <-ready:
+	case <-time.After(embeddedReadyTimeout):
+		path.stopIfIdle()
+		return &base.Response{StatusCode: base.StatusServiceUnavailable}, nil, nil
+	}
+
+	// Stop pulling if the client never plays
+	time.AfterFunc(embeddedIdleTimeout, path.stopIfIdle)
+
+	path.mu.Lock()
+	defer path.mu.Unlock()
+	if path.stream == nil {
+		return &base.Response{StatusCode: base.StatusServiceUnavailable}, nil, nil
+	}
+
+	return &base.Response{StatusCode: base.StatusOK}, path.stream, nil
+}
+
+// OnSetup serves the stream of a ready path
+func (e *embedded) OnSetup(ctx *gortsplib.ServerHandlerOnSetupCtx) (*base.Response, *gortsplib.ServerStream, error) {
+	path := e.path(ctx.Path)
+	if path == nil {
+		return &base.Response{StatusCode: base.StatusNotFound}, nil, nil
+	}
+
+	path.mu.Lock()
+	defer path.mu.Unlock()
+	if path.stream == nil {
+		return &base.Response{StatusCode: base.StatusServiceUnavailable}, nil, nil
+	}
+
+	return &base.Response{StatusCode: base.StatusOK}, path.stream, nil
+}
+
+// OnPlay tracks the session as a reader of the path
+func (e *embedded) OnPlay(ctx *gortsplib.ServerHandlerOnPlayCtx) (*base.Response, error) {
+	path := e.path(ctx.Path)
+	if path == nil {
+		return &base.Response{StatusCode: base.StatusNotFound}, nil
+	}
+
+	path.mu.Lock()
+	path.readers[ctx.Session] = struct{}{}
+	path.mu.Unlock()
+	ctx.Session.SetUserData(path)
+
+	return &base.Response{StatusCode: base.StatusOK}, nil
+}
+
+// OnSessionClose untracks the reader, the source stops once idle
+func (e *embedded) OnSessionClose(ctx *gortsplib.ServerHandlerOnSessionCloseCtx) {
+	path, ok := ctx.Session.UserData().(*embeddedPath)
+	if !ok {
+		return
+	}
+
+	path.mu.Lock()
+	delete(path.readers, ctx.Session)
+	idle := len(path.readers) == 0
+	path.mu.Unlock()
+
+	if idle {
+		time.AfterFunc(embeddedIdleTimeout, path.stopIfIdle)
+	}
+}
+
+// start pulls the source if not already, it returns the ready channel
+func (p *embeddedPath) start() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cancel == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		p.cancel = cancel
+		go p.run(ctx)
+	}
+	return p.ready
+}
+
+// stop stops pulling the source
+func (p *embeddedPath) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cancel != nil {
+		p.cancel()
+		p.cancel = nil
+	}
+}
+
+// stopIfIdle stops pulling the source when nobody reads the path
+func (p *embeddedPath) stopIfIdle() {
+	p.mu.Lock()
+	idle := len(p.readers) == 0
+	p.mu.Unlock()
+
+	if idle {
+		p.stop()
+	}
+}
+
+// run pulls the source until ctx is done, reconnecting on error
+func (p *embeddedPath) run(ctx context.Context) {
+	for {
+		err := p.pull(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		pkg.LogWarn(fmt.Sprintf("embedded path %s source error: %v", p.name, err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(embeddedReconnectPause):
+		}
+	}
+}
+
+// pull reads the source and writes its packets to the path stream
+func (p *embeddedPath) pull(ctx context.Context) error {
+	u, err := base.ParseURL(p.source)
+	if err != nil {
+		return err
+	}
+
+	client := gortsplib.Client{}
+	if err := client.Start(u.Scheme, u.Host); err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// Close the client when the path stops
+	stop := context.AfterFunc(ctx, client.Close)
+	defer stop()
+
+	desc, _, err := client.Describe(u)
+	if err != nil {
+		return err
+	}
+	if err := client.SetupAll(desc.BaseURL, desc.Medias); err != nil {
+		return err
+	}
+
+	stream := p.setReady(embeddedInstance.server, desc)
+	defer p.setUnready(stream)
+
+	// Route incoming packets to the path stream
+	client.OnPacketRTPAny(func(medi *description.Media, forma format.Format, pkt *rtp.Packet) {
+		stream.WritePacketRTP(medi, pkt)
+	})
+
+	if _, err := client.Play(nil); err != nil {
+		return err
+	}
+
+	return client.Wait()
+}
+
+func (p *embeddedPath) setReady(server *gortsplib.Server, desc *description.Session) *gortsplib.ServerStream {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// A previous pull may not be done yet
+	if p.stream != nil {
+		p.stream.Close()
+	}
+	p.stream = gortsplib.NewServerStream(server, desc)
+
+	select {
+	case <-p.ready:
+	default:
+		close(p.ready)
+	}
+	return p.stream
+}
+
+func (p *embeddedPath) setUnready(stream *gortsplib.ServerStream) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Closing the stream disconnects its readers
+	stream.Close()
+	if p.stream == stream {
+		p.stream = nil
+		p.ready = make(chan struct{})
+	}
+}
+
+// sessionId identifies a server session
+func sessionId(session *gortsplib.ServerSession) string {
+	return fmt.Sprintf("%p", session)
+}
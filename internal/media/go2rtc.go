@@ -0,0 +1,211 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"stream-session-api/domain"
+	"stream-session-api/dto"
+	"stream-session-api/internal/conf/network"
+	"stream-session-api/pkg"
+	"strings"
+	"time"
+)
+
+type go2Rtc struct {
+	conf network.Go2Rtc
+}
+
+// NewGo2Rtc returns a media backend talking to the go2rtc http api.
+// A path is a go2rtc stream and its consumers are the readers.
+func NewGo2Rtc(conf network.Go2Rtc) domain.MediaBackend {
+	return &go2Rtc{conf: conf}
+}
+
+// url builds a http api url
+func (g *go2Rtc) url(path string, query url.Values) string {
+	u := fmt.Sprintf("http://%s:%d%s", g.conf.Http.Ip, g.conf.Http.Port, path)
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u
+}
+
+func (g *go2Rtc) Info(ctx context.Context) (*domain.MediaInfo, error) {
+	client := pkg.NewHttpClient()
+	resp, err := client.R().
+		SetContext(ctx).
+		SetHeader("Accept", "application/json").
+		SetResult(&dto.Go2RtcInfo{}).
+		Get(g.url("/api", nil))
+	if err != nil {
+		return nil, pkg.NewError(pkg.ErrUnavailable, err)
+	}
+	if resp.StatusCode() != 200 {
+		return nil, statusError(resp, "failed to get media server info")
+	}
+
+	// go2rtc does not expose its start time, a new pid means a restart
+	info := resp.Result().(*dto.Go2RtcInfo)
+	return &domain.MediaInfo{Version: info.Version, Started: strconv.Itoa(info.Pid)}, nil
+}
+
+func (g *go2Rtc) CreatePath(ctx context.Context, name, source string) error {
+	client := pkg.NewHttpClient()
+	resp, err := client.R().
+		SetContext(ctx).
+		Put(g.url("/api/streams", url.Values{"name": {name}, "src": {source}}))
+	if err != nil {
+		return pkg.NewError(pkg.ErrUnavailable, err)
+	}
+	if resp.StatusCode() != 200 {
+		return statusError(resp, "failed to add stream")
+	}
+
+	return nil
+}
+
+func (g *go2Rtc) CreateCommandPath(ctx context.Context, name, command string) error {
+	return pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("go2rtc cannot run a path command"))
+}
+
+func (g *go2Rtc) DeletePath(ctx context.Context, name string) error {
+	client := pkg.NewHttpClient()
+	resp, err := client.R().
+		SetContext(ctx).
+		Delete(g.url("/api/streams", url.Values{"src": {name}}))
+	if err != nil {
+		return pkg.NewError(pkg.ErrUnavailable, err)
+	}
+	if resp.StatusCode() != 200 {
+		return statusError(resp, "failed to delete stream")
+	}
+
+	return nil
+}
+
+func (g *go2Rtc) ListReaders(ctx context.Context) ([]domain.Reader, error) {
+	streams := map[string]dto.Go2RtcStream{}
+
+	client := pkg.NewHttpClient()
+	resp, err := client.R().
+		SetContext(ctx).
+		SetHeader("Accept", "application/json").
+		SetResult(&streams).
+		Get(g.url("/api/streams", nil))
+	if err != nil {
+		return nil, pkg.NewError(pkg.ErrUnavailable, err)
+	}
+	if resp.StatusCode() != 200 {
+		return nil, statusError(resp, "failed to list streams")
+	}
+
+	var readers []domain.Reader
+	for name, stream := range streams {
+		for _, consumer := range stream.Consumers {
+			readers = append(readers, domain.Reader{
+				Id:        strconv.FormatUint(uint64(consumer.Id), 10),
+				Type:      consumerType(consumer),
+				Path:      name,
+				BytesSent: consumer.BytesSend,
+			})
+		}
+	}
+
+	return readers, nil
+}
+
+func (g *go2Rtc) KickReader(ctx context.Context, reader domain.Reader) error {
+	return pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("go2rtc cannot kick a consumer"))
+}
+
+func (g *go2Rtc) PathStatus(ctx context.Context, name string) (*domain.PathStatus, error) {
+	client := pkg.NewHttpClient()
+	resp, err := client.R().
+		SetContext(ctx).
+		SetHeader("Accept", "application/json").
+		SetResult(&dto.Go2RtcStream{}).
+		Get(g.url("/api/streams", url.Values{"src": {name}}))
+	if err != nil {
+		return nil, pkg.NewError(pkg.ErrUnavailable, err)
+	}
+	if resp.StatusCode() != 200 {
+		return nil, statusError(resp, "failed to get stream")
+	}
+
+	stream := resp.Result().(*dto.Go2RtcStream)
+	status := &domain.PathStatus{
+		Name:    name,
+		Readers: len(stream.Consumers),
+	}
+	for _, producer := range stream.Producers {
+		if status.Source == "" {
+			status.Source = producer.Url
+		}
+		// A producer is connected once its medias are known
+		if len(producer.Medias) > 0 {
+			status.Ready = true
+			status.Tracks = append(status.Tracks, producer.Medias...)
+		}
+		status.BytesReceived += producer.BytesRecv
+	}
+	for _, consumer := range stream.Consumers {
+		status.BytesSent += consumer.BytesSend
+	}
+
+	return status, nil
+}
+
+func (g *go2Rtc) BuildPlaybackURL(name string) string {
+	return g.url("/stream.html", url.Values{"src": {name}, "mode": {"webrtc"}})
+}
+
+func (g *go2Rtc) BuildPlaybackURLs(name string) map[string]string {
+	return map[string]string{
+		domain.ProtocolWhep:   g.url("/api/webrtc", url.Values{"src": {name}}),
+		domain.ProtocolWebRtc: g.BuildPlaybackURL(name),
+		domain.ProtocolHls:    g.url("/api/stream.m3u8", url.Values{"src": {name}}),
+	}
+}
+
+func (g *go2Rtc) SetRecord(ctx context.Context, name string, options *domain.RecordOptions) error {
+	return pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("go2rtc cannot record a path"))
+}
+
+func (g *go2Rtc) RecordSegments(ctx context.Context, name string, options domain.RecordOptions) ([]domain.RecordSegment, error) {
+	return nil, pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("go2rtc cannot record a path"))
+}
+
+func (g *go2Rtc) ListRecordSpans(ctx context.Context, name string, start, end time.Time) ([]domain.RecordSpan, error) {
+	return nil, pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("go2rtc cannot record a path"))
+}
+
+func (g *go2Rtc) DeleteRecordSegment(ctx context.Context, name string, start time.Time) error {
+	return pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("go2rtc cannot record a path"))
+}
+
+// BuildPublishURLs returns no url, publishing is not supported
+func (g *go2Rtc) BuildPublishURLs(name, credential string) map[string]string {
+	return nil
+}
+
+// BuildRecordURL returns no url, recording is not supported
+func (g *go2Rtc) BuildRecordURL(name string, start time.Time, duration time.Duration, format string) string {
+	return ""
+}
+
+// consumerType maps a go2rtc consumer format to a reader type
+func consumerType(consumer dto.Go2RtcConnection) string {
+	format := strings.ToLower(consumer.FormatName)
+	switch {
+	case strings.HasPrefix(format, "webrtc"):
+		return "webrtc"
+	case strings.Contains(format, "hls"):
+		return "hls"
+	case strings.HasPrefix(format, "rtsp"):
+		return "rtsp"
+	default:
+		return format
+	}
+}
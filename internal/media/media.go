@@ -0,0 +1,67 @@
+package media
+
+import (
+	"stream-session-api/domain"
+	"stream-session-api/internal/conf/network"
+)
+
+// Media backend names, see network.NetCfg.Media
+const (
+	BackendMediaMtx = "mediamtx"
+	BackendGo2Rtc   = "go2rtc"
+	BackendEmbedded = "embedded"
+)
+
+// Nodes returns the names of the media nodes. Only the mediamtx backend
+// has a pool of nodes, other backends are a single node named after them.
+func Nodes() []string {
+	conf := network.Get()
+	switch conf.Media {
+	case BackendGo2Rtc, BackendEmbedded:
+		return []string{conf.Media}
+	default:
+		names := make([]string, 0, len(conf.MediaMtx))
+		for _, node := range conf.MediaMtx {
+			names = append(names, node.Name)
+		}
+		return names
+	}
+}
+
+// Node returns the media backend of a node, the default node if not found
+func Node(name string) domain.MediaBackend {
+	conf := network.Get()
+	switch conf.Media {
+	case BackendGo2Rtc:
+		return NewGo2Rtc(conf.Go2Rtc)
+	case BackendEmbedded:
+		return NewEmbedded(conf.Embedded)
+	default:
+		return NewMediaMtx(conf.Node(name))
+	}
+}
+
+// NodeName returns the name of a node, the default node if not found
+func NodeName(name string) string {
+	conf := network.Get()
+	switch conf.Media {
+	case BackendGo2Rtc, BackendEmbedded:
+		return conf.Media
+	default:
+		return conf.Node(name).Name
+	}
+}
+
+// Init starts the in-process media backend, if any
+func Init() error {
+	conf := network.Get()
+	if conf.Media == BackendEmbedded {
+		return startEmbedded(conf.Embedded)
+	}
+	return nil
+}
+
+// Close releases the in-process media backend, if any
+func Close() {
+	closeEmbedded()
+}
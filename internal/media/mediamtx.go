@@ -0,0 +1,488 @@
+package media
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"stream-session-api/domain"
+	"stream-session-api/dto"
+	"stream-session-api/internal/conf/network"
+	"stream-session-api/pkg"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+type mediaMtx struct {
+	conf network.MediaMtx
+}
+
+// NewMediaMtx returns a media backend talking to the MediaMTX control API
+func NewMediaMtx(conf network.MediaMtx) domain.MediaBackend {
+	return &mediaMtx{conf: conf}
+}
+
+// url builds a control API url
+func (m *mediaMtx) url(format string, a ...interface{}) string {
+	return fmt.Sprintf("http://%s:%d", m.conf.Http.Ip, m.conf.Http.Port) + fmt.Sprintf(format, a...)
+}
+
+// statusError maps an unexpected control API response to an error
+func statusError(resp *resty.Response, msg string) error {
+	err := fmt.Errorf("%d %s", resp.StatusCode(), msg)
+	switch resp.StatusCode() {
+	case 400:
+		return pkg.NewError(pkg.ErrBadRequest, err)
+	case 404:
+		return pkg.NewError(pkg.ErrNotFound, err)
+	default:
+		return pkg.NewError(pkg.ErrInternalFailure, err)
+	}
+}
+
+func (m *mediaMtx) Info(ctx context.Context) (*domain.MediaInfo, error) {
+	client := pkg.NewHttpClient()
+	resp, err := client.R().
+		SetContext(ctx).
+		SetHeader("Accept", "application/json").
+		SetResult(&dto.MediaServerInfo{}).
+		Get(m.url("/v3/info"))
+	if err != nil {
+		return nil, pkg.NewError(pkg.ErrUnavailable, err)
+	}
+	if resp.StatusCode() != 200 {
+		return nil, statusError(resp, "failed to get media server info")
+	}
+
+	info := resp.Result().(*dto.MediaServerInfo)
+	return &domain.MediaInfo{Version: info.Version, Started: info.Started}, nil
+}
+
+func (m *mediaMtx) CreatePath(ctx context.Context, name, source string) error {
+	// Set body for http post
+	body, _ := json.Marshal(
+		struct {
+			Name   string `json:"name"`
+			Source string `json:"source"`
+		}{
+			Name:   name,
+			Source: source,
+		},
+	)
+
+	client := pkg.NewHttpClient()
+	resp, err := client.R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetBody(string(body)).
+		Post(m.url("/v3/config/paths/add/%s", name))
+	if err != nil {
+		return pkg.NewError(pkg.ErrUnavailable, err)
+	}
+	if resp.StatusCode() != 200 {
+		return statusError(resp, "failed to add path")
+	}
+
+	return nil
+}
+
+func (m *mediaMtx) CreateCommandPath(ctx context.Context, name, command string) error {
+	// The command publishes to the path, MediaMTX restarts it when it exits
+	body, _ := json.Marshal(
+		struct {
+			Name             string `json:"name"`
+			Source           string `json:"source"`
+			RunOnInit        string `json:"runOnInit"`
+			RunOnInitRestart bool   `json:"runOnInitRestart"`
+		}{
+			Name:             name,
+			Source:           "publisher",
+			RunOnInit:        command,
+			RunOnInitRestart: true,
+		},
+	)
+
+	client := pkg.NewHttpClient()
+	resp, err := client.R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetBody(string(body)).
+		Post(m.url("/v3/config/paths/add/%s", name))
+	if err != nil {
+		return pkg.NewError(pkg.ErrUnavailable, err)
+	}
+	if resp.StatusCode() != 200 {
+		return statusError(resp, "failed to add command path")
+	}
+
+	return nil
+}
+
+func (m *mediaMtx) DeletePath(ctx context.Context, name string) error {
+	client := pkg.NewHttpClient()
+	resp, err := client.R().
+		SetContext(ctx).
+		Delete(m.url("/v3/config/paths/delete/%s", name))
+	if err != nil {
+		return pkg.NewError(pkg.ErrUnavailable, err)
+	}
+	if resp.StatusCode() != 200 {
+		return statusError(resp, "failed to delete path")
+	}
+
+	return nil
+}
+
+func (m *mediaMtx) ListReaders(ctx context.Context) ([]domain.Reader, error) {
+	// Bytes sent to each webrtc session
+	webrtcBytes := make(map[string]uint64)
+	for page := 0; ; page++ {
+		client := pkg.NewHttpClient()
+		resp, err := client.R().
+			SetContext(ctx).
+			SetHeader("Accept", "application/json").
+			SetResult(&dto.StreamSessionList{}).
+			Get(m.url("/v3/webrtcsessions/list?page=%d", page))
+		if err != nil {
+			return nil, pkg.NewError(pkg.ErrUnavailable, err)
+		}
+		if resp.StatusCode() != 200 {
+			return nil, statusError(resp, "failed to list webrtc sessions")
+		}
+
+		sessions := resp.Result().(*dto.StreamSessionList)
+		for _, session := range sessions.Items {
+			webrtcBytes[session.ID] = uint64(session.BytesSent)
+		}
+		if page+1 >= sessions.PageCount {
+			break
+		}
+	}
+
+	// Readers of every protocol by path
+	var readers []domain.Reader
+	for page := 0; ; page++ {
+		client := pkg.NewHttpClient()
+		resp, err := client.R().
+			SetContext(ctx).
+			SetHeader("Accept", "application/json").
+			SetResult(&dto.PathList{}).
+			Get(m.url("/v3/paths/list?page=%d", page))
+		if err != nil {
+			return nil, pkg.NewError(pkg.ErrUnavailable, err)
+		}
+		if resp.StatusCode() != 200 {
+			return nil, statusError(resp, "failed to list paths")
+		}
+
+		paths := resp.Result().(*dto.PathList)
+		for _, path := range paths.Items {
+			readers = append(readers, pathReaders(path, webrtcBytes)...)
+		}
+		if page+1 >= paths.PageCount {
+			break
+		}
+	}
+
+	return readers, nil
+}
+
+// pathReaders maps the readers of a path, the bytes sent by the path beyond
+// its webrtc sessions are shared evenly among the readers without counter
+func pathReaders(path dto.Path, webrtcBytes map[string]uint64) []domain.Reader {
+	readers := make([]domain.Reader, 0, len(path.Readers))
+	shared := path.BytesSent
+	others := 0
+	for _, reader := range path.Readers {
+		r := domain.Reader{Id: reader.Id, Type: readerType(reader.Type), Path: path.Name}
+		if r.Type == "webrtc" {
+			r.BytesSent = webrtcBytes[reader.Id]
+			shared -= min(shared, r.BytesSent)
+		} else {
+			others++
+		}
+		readers = append(readers, r)
+	}
+
+	for i := range readers {
+		if readers[i].Type != "webrtc" {
+			readers[i].BytesSent = shared / uint64(others)
+		}
+	}
+
+	return readers
+}
+
+// readerType maps a MediaMTX reader type to its protocol
+func readerType(kind string) string {
+	switch kind {
+	case "webRTCSession":
+		return "webrtc"
+	case "rtspSession":
+		return "rtsp"
+	case "rtspsSession":
+		return "rtsps"
+	case "rtmpConn":
+		return "rtmp"
+	case "rtmpsConn":
+		return "rtmps"
+	case "srtConn":
+		return "srt"
+	case "hlsMuxer":
+		return "hls"
+	default:
+		return kind
+	}
+}
+
+func (m *mediaMtx) KickReader(ctx context.Context, reader domain.Reader) error {
+	if reader.Type != "webrtc" {
+		return pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("unsupported reader type %q", reader.Type))
+	}
+
+	client := pkg.NewHttpClient()
+	resp, err := client.R().
+		SetContext(ctx).
+		Post(m.url("/v3/webrtcsessions/kick/%s", reader.Id))
+	if err != nil {
+		return pkg.NewError(pkg.ErrUnavailable, err)
+	}
+	if resp.StatusCode() != 200 {
+		return statusError(resp, "failed to kick webrtc session")
+	}
+
+	return nil
+}
+
+func (m *mediaMtx) PathStatus(ctx context.Context, name string) (*domain.PathStatus, error) {
+	client := pkg.NewHttpClient()
+	resp, err := client.R().
+		SetContext(ctx).
+		SetHeader("Accept", "application/json").
+		SetResult(&dto.Path{}).
+		Get(m.url("/v3/paths/get/%s", name))
+	if err != nil {
+		return nil, pkg.NewError(pkg.ErrUnavailable, err)
+	}
+	if resp.StatusCode() != 200 {
+		return nil, statusError(resp, "failed to get path")
+	}
+
+	path := resp.Result().(*dto.Path)
+	status := &domain.PathStatus{
+		Name:          path.Name,
+		Ready:         path.Ready,
+		Tracks:        path.Tracks,
+		Readers:       len(path.Readers),
+		BytesReceived: path.BytesReceived,
+		BytesSent:     path.BytesSent,
+	}
+	if path.Source != nil {
+		status.Source = path.Source.Type
+	}
+
+	return status, nil
+}
+
+func (m *mediaMtx) SetRecord(ctx context.Context, name string, options *domain.RecordOptions) error {
+	// Patch the recording settings of the path
+	record := map[string]interface{}{"record": options != nil}
+	if options != nil {
+		record["recordFormat"] = options.Format
+		record["recordSegmentDuration"] = options.SegmentDuration.String()
+		record["recordPath"] = options.Path
+	}
+	body, _ := json.Marshal(record)
+
+	client := pkg.NewHttpClient()
+	resp, err := client.R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetBody(string(body)).
+		Patch(m.url("/v3/config/paths/patch/%s", name))
+	if err != nil {
+		return pkg.NewError(pkg.ErrUnavailable, err)
+	}
+	if resp.StatusCode() != 200 {
+		return statusError(resp, "failed to set path recording")
+	}
+
+	return nil
+}
+
+func (m *mediaMtx) RecordSegments(ctx context.Context, name string, options domain.RecordOptions) ([]domain.RecordSegment, error) {
+	client := pkg.NewHttpClient()
+	resp, err := client.R().
+		SetContext(ctx).
+		SetHeader("Accept", "application/json").
+		SetResult(&dto.Recording{}).
+		Get(m.url("/v3/recordings/get/%s", name))
+	if err != nil {
+		return nil, pkg.NewError(pkg.ErrUnavailable, err)
+	}
+	if resp.StatusCode() != 200 {
+		return nil, statusError(resp, "failed to get path recordings")
+	}
+
+	recording := resp.Result().(*dto.Recording)
+	segments := make([]domain.RecordSegment, 0, len(recording.Segments))
+	for _, segment := range recording.Segments {
+		start, err := time.Parse(time.RFC3339Nano, segment.Start)
+		if err != nil {
+			return nil, pkg.NewError(pkg.ErrInternalFailure, err)
+		}
+		segments = append(segments, domain.RecordSegment{
+			Start: start,
+			File:  recordFile(options, name, start),
+		})
+	}
+
+	return segments, nil
+}
+
+func (m *mediaMtx) ListRecordSpans(ctx context.Context, name string, start, end time.Time) ([]domain.RecordSpan, error) {
+	if m.conf.Playback.Port == 0 {
+		return nil, pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("playback server of media node %s not configured", m.conf.Name))
+	}
+
+	client := pkg.NewHttpClient()
+	resp, err := client.R().
+		SetContext(ctx).
+		SetHeader("Accept", "application/json").
+		SetQueryParams(map[string]string{
+			"path":  name,
+			"start": start.Format(time.RFC3339Nano),
+			"end":   end.Format(time.RFC3339Nano),
+		}).
+		SetResult(&[]dto.PlaybackSpan{}).
+		Get(fmt.Sprintf("http://%s:%d/list", m.conf.Playback.Ip, m.conf.Playback.Port))
+	if err != nil {
+		return nil, pkg.NewError(pkg.ErrUnavailable, err)
+	}
+	// No recording in the range
+	if resp.StatusCode() == 404 {
+		return nil, nil
+	}
+	if resp.StatusCode() != 200 {
+		return nil, statusError(resp, "failed to list recordings")
+	}
+
+	list := *resp.Result().(*[]dto.PlaybackSpan)
+	spans := make([]domain.RecordSpan, 0, len(list))
+	for _, span := range list {
+		start, err := time.Parse(time.RFC3339Nano, span.Start)
+		if err != nil {
+			return nil, pkg.NewError(pkg.ErrInternalFailure, err)
+		}
+		spans = append(spans, domain.RecordSpan{
+			Start:    start,
+			Duration: time.Duration(span.Duration * float64(time.Second)),
+		})
+	}
+
+	return spans, nil
+}
+
+func (m *mediaMtx) DeleteRecordSegment(ctx context.Context, name string, start time.Time) error {
+	client := pkg.NewHttpClient()
+	resp, err := client.R().
+		SetContext(ctx).
+		SetQueryParams(map[string]string{
+			"path":  name,
+			"start": start.Format(time.RFC3339Nano),
+		}).
+		Delete(m.url("/v3/recordings/deletesegment"))
+	if err != nil {
+		return pkg.NewError(pkg.ErrUnavailable, err)
+	}
+	if resp.StatusCode() != 200 {
+		return statusError(resp, "failed to delete recording segment")
+	}
+
+	return nil
+}
+
+// recordFile returns the file of a segment, as MediaMTX expands recordPath
+func recordFile(options domain.RecordOptions, name string, start time.Time) string {
+	file := strings.NewReplacer(
+		"%path", name,
+		"%Y", start.Format("2006"),
+		"%m", start.Format("01"),
+		"%d", start.Format("02"),
+		"%H", start.Format("15"),
+		"%M", start.Format("04"),
+		"%S", start.Format("05"),
+		"%f", fmt.Sprintf("%06d", start.Nanosecond()/1000),
+		"%s", strconv.FormatInt(start.Unix(), 10),
+	).Replace(options.Path)
+
+	if options.Format == domain.RecordFormatMpegTs {
+		return file + ".ts"
+	}
+	return file + ".mp4"
+}
+
+func (m *mediaMtx) BuildPlaybackURL(name string) string {
+	return fmt.Sprintf("http://%s:%d/%s", m.conf.WebRtc.Ip, m.conf.WebRtc.Port, name)
+}
+
+func (m *mediaMtx) BuildPlaybackURLs(name string) map[string]string {
+	urls := map[string]string{
+		domain.ProtocolWhep:   fmt.Sprintf("http://%s:%d/%s/whep", m.conf.WebRtc.Ip, m.conf.WebRtc.Port, name),
+		domain.ProtocolWebRtc: fmt.Sprintf("http://%s:%d/%s", m.conf.WebRtc.Ip, m.conf.WebRtc.Port, name),
+		domain.ProtocolRtsp:   fmt.Sprintf("rtsp://%s:%d/%s", m.conf.Rtsp.Ip, m.conf.Rtsp.Port, name),
+	}
+
+	// Optional servers, disabled without port
+	if m.conf.Hls.Port != 0 {
+		urls[domain.ProtocolHls] = fmt.Sprintf("http://%s:%d/%s/index.m3u8", m.conf.Hls.Ip, m.conf.Hls.Port, name)
+	}
+	if m.conf.LlHls.Port != 0 {
+		urls[domain.ProtocolLlHls] = fmt.Sprintf("http://%s:%d/%s/index.m3u8", m.conf.LlHls.Ip, m.conf.LlHls.Port, name)
+	}
+	if m.conf.Rtsps.Port != 0 {
+		urls[domain.ProtocolRtsps] = fmt.Sprintf("rtsps://%s:%d/%s", m.conf.Rtsps.Ip, m.conf.Rtsps.Port, name)
+	}
+	if m.conf.Srt.Port != 0 {
+		urls[domain.ProtocolSrt] = fmt.Sprintf("srt://%s:%d?streamid=read:%s", m.conf.Srt.Ip, m.conf.Srt.Port, name)
+	}
+	if m.conf.Rtmp.Port != 0 {
+		urls[domain.ProtocolRtmp] = fmt.Sprintf("rtmp://%s:%d/%s", m.conf.Rtmp.Ip, m.conf.Rtmp.Port, name)
+	}
+
+	return urls
+}
+
+func (m *mediaMtx) BuildPublishURLs(name, credential string) map[string]string {
+	urls := map[string]string{
+		domain.ProtocolWhip: fmt.Sprintf("http://%s:%d/%s/whip?token=%s", m.conf.WebRtc.Ip, m.conf.WebRtc.Port, name, credential),
+	}
+
+	// Optional servers, disabled without port
+	if m.conf.Rtmp.Port != 0 {
+		urls[domain.ProtocolRtmp] = fmt.Sprintf("rtmp://%s:%d/%s?user=publisher&pass=%s", m.conf.Rtmp.Ip, m.conf.Rtmp.Port, name, credential)
+	}
+	if m.conf.Srt.Port != 0 {
+		urls[domain.ProtocolSrt] = fmt.Sprintf("srt://%s:%d?streamid=publish:%s:publisher:%s", m.conf.Srt.Ip, m.conf.Srt.Port, name, credential)
+	}
+
+	return urls
+}
+
+func (m *mediaMtx) BuildRecordURL(name string, start time.Time, duration time.Duration, format string) string {
+	if m.conf.Playback.Port == 0 {
+		return ""
+	}
+
+	query := url.Values{}
+	query.Set("path", name)
+	query.Set("start", start.Format(time.RFC3339Nano))
+	query.Set("duration", strconv.FormatFloat(duration.Seconds(), 'f', -1, 64))
+	if format != "" {
+		query.Set("format", format)
+	}
+	return fmt.Sprintf("http://%s:%d/get?%s", m.conf.Playback.Ip, m.conf.Playback.Port, query.Encode())
+}
@@ -0,0 +1,189 @@
+package placement
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"stream-session-api/domain"
+	"stream-session-api/internal/conf/network"
+	"stream-session-api/internal/media"
+	"stream-session-api/pkg"
+	"sync"
+	"time"
+)
+
+// Placement strategies, see network.NetCfg.Placement
+const (
+	LeastSessions  = "least-sessions"  // Fewest stream sessions per weight
+	LeastBandwidth = "least-bandwidth" // Lowest bandwidth sent to readers per weight
+	Hash           = "hash"            // Weighted rendezvous hashing by stream id
+)
+
+// sample is the bytes sent to the readers of a node at a time
+type sample struct {
+	bytes map[string]uint64 // By reader
+	at    time.Time
+	rate  float64 // Kbit/s since the previous sample
+}
+
+// sampleMaxAge is the age past which placement samples a node itself
+const sampleMaxAge = time.Minute
+
+var (
+	samplesMu sync.Mutex
+	samples   = make(map[string]sample)
+)
+
+// candidate is a node with its current load
+type candidate struct {
+	node     network.MediaMtx
+	sessions uint
+	rate     float64
+}
+
+// Place chooses the media node of a new stream session for streamId.
+// streams are the current stream sessions, used to count sessions per node.
+// Nodes in exclude take no new session.
+func Place(ctx context.Context, streamId string, streams []*domain.Stream, exclude map[string]bool) (string, error) {
+	conf := network.Get()
+
+	// Only the mediamtx backend has a pool
+	if conf.Media != "" && conf.Media != media.BackendMediaMtx {
+		return media.Nodes()[0], nil
+	}
+	if len(conf.MediaMtx) == 0 {
+		return "", pkg.NewError(pkg.ErrResourceNotFound, fmt.Errorf("no media node configured"))
+	}
+
+	// Count sessions per node, records without node are on the default node
+	sessions := make(map[string]uint)
+	for _, stream := range streams {
+		sessions[conf.Node(stream.Node).Name]++
+	}
+
+	// Keep nodes under their capacity
+	var candidates []candidate
+	for _, node := range conf.MediaMtx {
+		// Origin nodes only pull cameras for the edges
+		if exclude[node.Name] || node.Role == network.RoleOrigin {
+			continue
+		}
+
+		c := candidate{node: node, sessions: sessions[node.Name]}
+		if node.MaxSessions > 0 && c.sessions >= node.MaxSessions {
+			continue
+		}
+
+		if conf.Placement == LeastBandwidth || node.MaxBandwidth > 0 {
+			rate, err := bandwidth(ctx, node.Name)
+			if err != nil {
+				pkg.LogWarnContext(ctx, fmt.Sprintf("skip media node %s: %v", node.Name, err))
+				continue
+			}
+			if node.MaxBandwidth > 0 && rate >= float64(node.MaxBandwidth) {
+				continue
+			}
+			c.rate = rate
+		}
+
+		candidates = append(candidates, c)
+	}
+	if len(candidates) == 0 {
+		return "", pkg.NewError(pkg.ErrResourceNotFound, fmt.Errorf("every media node is at capacity"))
+	}
+
+	var best candidate
+	bestScore := math.Inf(-1)
+	for _, c := range candidates {
+		weight := float64(c.node.Weight)
+		if weight <= 0 {
+			weight = 1
+		}
+
+		// Higher score wins
+		var score float64
+		switch conf.Placement {
+		case LeastBandwidth:
+			score = -c.rate / weight
+		case Hash:
+			score = rendezvous(c.node.Name, streamId, weight)
+		default:
+			score = -float64(c.sessions) / weight
+		}
+
+		if score > bestScore {
+			best, bestScore = c, score
+		}
+	}
+
+	pkg.LogInfoContext(ctx, fmt.Sprintf("stream %s placed on media node %s", streamId, best.node.Name))
+	return best.node.Name, nil
+}
+
+// bandwidth returns the rate sent to readers by a node in Kbit/s, from the
+// last sample of the periodic session check, else from a new sample.
+func bandwidth(ctx context.Context, node string) (float64, error) {
+	samplesMu.Lock()
+	last, ok := samples[node]
+	samplesMu.Unlock()
+	if ok && time.Since(last.at) < sampleMaxAge {
+		return last.rate, nil
+	}
+
+	readers, err := media.Node(node).ListReaders(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return Sample(node, readers), nil
+}
+
+// Sample records the bytes sent to the readers of a node and returns the
+// rate in Kbit/s since the previous sample. Readers which left are not
+// counted, new ones count from their start.
+func Sample(node string, readers []domain.Reader) float64 {
+	bytes := make(map[string]uint64, len(readers))
+	for _, reader := range readers {
+		bytes[reader.Type+":"+reader.Id] += reader.BytesSent
+	}
+
+	samplesMu.Lock()
+	defer samplesMu.Unlock()
+
+	now := time.Now()
+	prev, ok := samples[node]
+	next := sample{bytes: bytes, at: now, rate: prev.rate}
+	if ok && now.Sub(prev.at) > time.Second {
+		var sent uint64
+		for id, value := range bytes {
+			if value >= prev.bytes[id] {
+				sent += value - prev.bytes[id]
+			}
+		}
+		next.rate = float64(sent) * 8 / 1000 / now.Sub(prev.at).Seconds()
+	} else if ok {
+		// Too close to the previous sample, keep it as reference
+		next = prev
+	}
+	samples[node] = next
+
+	return next.rate
+}
+
+// rendezvous is the weighted rendezvous hashing score of a node for a key
+func rendezvous(node, key string, weight float64) float64 {
+	h := fnv.New64a()
+	h.Write([]byte(node + "/" + key))
+
+	// Mix the bits, fnv leaves the high ones of close keys alike
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+
+	// Map the hash to (0, 1)
+	u := (float64(x>>11) + 0.5) / float64(uint64(1)<<53)
+	return -weight / math.Log(u)
+}
@@ -0,0 +1,133 @@
+package placement
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"stream-session-api/domain"
+	"stream-session-api/internal/conf/network"
+	"testing"
+	"time"
+)
+
+// pick returns the node of the highest rendezvous score for key
+func pick(weights map[string]float64, key string) string {
+	var best string
+	bestScore := math.Inf(-1)
+	for node, weight := range weights {
+		if score := rendezvous(node, key, weight); score > bestScore {
+			best, bestScore = node, score
+		}
+	}
+	return best
+}
+
+func TestRendezvous(t *testing.T) {
+	weights := map[string]float64{"edge-1": 1, "edge-2": 1, "edge-3": 2}
+
+	const keys = 20000
+	counts := make(map[string]int)
+	placed := make(map[string]string)
+	for i := 0; i < keys; i++ {
+		key := fmt.Sprintf("camera-%d", i)
+		node := pick(weights, key)
+		if again := pick(weights, key); again != node {
+			t.Fatalf("%s placed on %s then %s", key, node, again)
+		}
+		counts[node]++
+		placed[key] = node
+	}
+
+	// Shares follow the weights
+	for node, weight := range weights {
+		share := float64(counts[node]) / keys
+		if want := weight / 4; math.Abs(share-want) > 0.02 {
+			t.Errorf("%s share %.3f, want %.3f", node, share, want)
+		}
+	}
+
+	// Only the keys of a removed node move
+	delete(weights, "edge-2")
+	for key, node := range placed {
+		if node != "edge-2" && pick(weights, key) != node {
+			t.Fatalf("%s moved from %s", key, node)
+		}
+	}
+}
+
+func TestPlaceHash(t *testing.T) {
+	conf := network.Get()
+	t.Cleanup(func() { network.Set(conf) })
+
+	next := conf
+	next.Media = ""
+	next.Placement = Hash
+	next.MediaMtx = []network.MediaMtx{
+		{Name: "origin", Role: network.RoleOrigin},
+		{Name: "edge-1"},
+		{Name: "edge-2"},
+		{Name: "edge-3", MaxSessions: 1},
+	}
+	network.Set(next)
+
+	ctx := context.Background()
+	full := []*domain.Stream{{Node: "edge-3"}}
+	for i := 0; i < 50; i++ {
+		streamId := fmt.Sprintf("camera-%d", i)
+		node, err := Place(ctx, streamId, full, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if node != "edge-1" && node != "edge-2" {
+			t.Fatalf("%s placed on %s", streamId, node)
+		}
+		if again, _ := Place(ctx, streamId, full, nil); again != node {
+			t.Fatalf("%s placed on %s then %s", streamId, node, again)
+		}
+
+		other := "edge-1"
+		if node == other {
+			other = "edge-2"
+		}
+		if excluded, _ := Place(ctx, streamId, full, map[string]bool{node: true}); excluded != other {
+			t.Fatalf("%s placed on %s with %s excluded", streamId, excluded, node)
+		}
+	}
+
+	if _, err := Place(ctx, "camera-0", full, map[string]bool{"edge-1": true, "edge-2": true}); err == nil {
+		t.Fatal("Place found a node while every edge is excluded or full")
+	}
+}
+
+func TestSample(t *testing.T) {
+	const node = "edge-test"
+	t.Cleanup(func() {
+		samplesMu.Lock()
+		delete(samples, node)
+		samplesMu.Unlock()
+	})
+
+	if rate := Sample(node, []domain.Reader{{Id: "a", Type: "webrtc", BytesSent: 1000}}); rate != 0 {
+		t.Fatalf("first sample rate %f, want 0", rate)
+	}
+
+	// Ten seconds later, a sent 10000 bytes, b joined with 5000 and c left
+	samplesMu.Lock()
+	samples[node] = sample{
+		bytes: map[string]uint64{"webrtc:a": 1000, "rtsp:c": 90000},
+		at:    time.Now().Add(-10 * time.Second),
+	}
+	samplesMu.Unlock()
+	rate := Sample(node, []domain.Reader{
+		{Id: "a", Type: "webrtc", BytesSent: 11000},
+		{Id: "b", Type: "rtsp", BytesSent: 5000},
+	})
+	if want := 15000.0 * 8 / 1000 / 10; math.Abs(rate-want) > 0.1 {
+		t.Fatalf("rate %f, want %f", rate, want)
+	}
+
+	// A sample right after keeps the reference and its rate
+	if again := Sample(node, nil); again != rate {
+		t.Fatalf("close sample rate %f, want %f", again, rate)
+	}
+}
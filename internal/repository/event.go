@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"stream-session-api/domain"
+	"stream-session-api/pkg"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type eventRepository struct {
+	client *redis.Client
+	ctx    context.Context
+	pubsub *redis.PubSub
+}
+
+// NewEvent returns a repository publishing events on redis pub/sub
+func NewEvent(ctx context.Context) domain.EventRepository {
+	return &eventRepository{
+		client: redisClient(),
+		ctx:    ctx,
+	}
+}
+
+// Close ends the subscription, if any
+func (r *eventRepository) Close() {
+	if r.pubsub != nil {
+		r.pubsub.Close()
+	}
+}
+
+func (r *eventRepository) Publish(event *domain.Event) error {
+	json, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return r.client.Publish(r.ctx, fmt.Sprintf("event:stream:%s", event.Owner), json).Err()
+}
+
+func (r *eventRepository) Subscribe(owner string) (<-chan *domain.Event, error) {
+	r.pubsub = r.client.Subscribe(r.ctx, fmt.Sprintf("event:stream:%s", owner))
+
+	// Wait for the subscription to be confirmed
+	if _, err := r.pubsub.Receive(r.ctx); err != nil {
+		r.pubsub.Close()
+		return nil, err
+	}
+
+	events := make(chan *domain.Event)
+	go func() {
+		defer close(events)
+		for msg := range r.pubsub.Channel() {
+			event := &domain.Event{}
+			if err := json.Unmarshal([]byte(msg.Payload), event); err != nil {
+				pkg.LogWarn(fmt.Sprintf("invalid event: %v", err))
+				continue
+			}
+			select {
+			case events <- event:
+			case <-r.ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
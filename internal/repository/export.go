@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"stream-session-api/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type exportRepository struct {
+	client *redis.Client
+	ctx    context.Context
+}
+
+func NewExport(ctx context.Context) domain.ExportRepository {
+	return &exportRepository{
+		client: redisClient(),
+		ctx:    ctx,
+	}
+}
+
+// Close releases the repository, the shared pool is closed by Shutdown
+func (r *exportRepository) Close() {}
+
+func (r *exportRepository) GetAll() ([]*domain.Export, error) {
+	var cursor uint64
+	var results []*domain.Export
+
+	for {
+		// Scan for matching keys
+		var keys []string
+		var err error
+		keys, cursor, err = r.client.Scan(r.ctx, cursor, "log:export:*", 0).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		// Fetch values for the keys, a key may be deleted meanwhile
+		for _, key := range keys {
+			value, err := r.client.Get(r.ctx, key).Result()
+			if err == redis.Nil {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			result := &domain.Export{}
+			if err := json.Unmarshal([]byte(value), result); err != nil {
+				return nil, err
+			}
+			results = append(results, result)
+		}
+
+		// Break if cursor is 0 (no more keys)
+		if cursor == 0 {
+			break
+		}
+	}
+
+	return results, nil
+}
+
+func (r *exportRepository) FindById(id string) *domain.Export {
+	value, err := r.client.Get(r.ctx, fmt.Sprintf("log:export:%s", id)).Result()
+	if err != nil {
+		return nil
+	}
+
+	var result *domain.Export
+	if err := json.Unmarshal([]byte(value), &result); err != nil {
+		return nil
+	}
+	if result.Id == id {
+		return result
+	}
+
+	return nil
+}
+
+func (r *exportRepository) Insert(export *domain.Export) error {
+	json, err := json.Marshal(export)
+	if err != nil {
+		return err
+	}
+
+	return r.client.Set(r.ctx, fmt.Sprintf("log:export:%s", export.Id), json, 0).Err()
+}
+
+func (r *exportRepository) Delete(id string) error {
+	return r.client.Del(r.ctx, fmt.Sprintf("log:export:%s", id)).Err()
+}
@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"stream-session-api/domain"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type idempotencyRepository struct {
+	client *redis.Client
+	ctx    context.Context
+}
+
+func NewIdempotency(ctx context.Context) domain.IdempotencyRepository {
+	return &idempotencyRepository{
+		client: redisClient(),
+		ctx:    ctx,
+	}
+}
+
+// Close releases the repository, the shared pool is closed by Shutdown
+func (r *idempotencyRepository) Close() {}
+
+func (r *idempotencyRepository) FindByKey(owner, key string) *domain.Idempotency {
+	value, err := r.client.Get(r.ctx, fmt.Sprintf("log:idempotency:%s:%s", owner, key)).Result()
+	if err != nil {
+		return nil
+	}
+
+	var result *domain.Idempotency
+	if err := json.Unmarshal([]byte(value), &result); err != nil {
+		return nil
+	}
+
+	return result
+}
+
+func (r *idempotencyRepository) Reserve(result *domain.Idempotency, ttl time.Duration) (bool, error) {
+	json, err := json.Marshal(result)
+	if err != nil {
+		return false, err
+	}
+
+	return r.client.SetNX(r.ctx, fmt.Sprintf("log:idempotency:%s:%s", result.Owner, result.Key), json, ttl).Result()
+}
+
+func (r *idempotencyRepository) Save(result *domain.Idempotency, ttl time.Duration) error {
+	json, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	return r.client.Set(r.ctx, fmt.Sprintf("log:idempotency:%s:%s", result.Owner, result.Key), json, ttl).Err()
+}
+
+func (r *idempotencyRepository) Delete(owner, key string) error {
+	return r.client.Del(r.ctx, fmt.Sprintf("log:idempotency:%s:%s", owner, key)).Err()
+}
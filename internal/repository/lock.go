@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"stream-session-api/domain"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// unlockScript deletes a lock only if it is still held by its token
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+type lockRepository struct {
+	client *redis.Client
+	ctx    context.Context
+}
+
+func NewLock(ctx context.Context) domain.LockRepository {
+	return &lockRepository{
+		client: redisClient(),
+		ctx:    ctx,
+	}
+}
+
+// Close releases the repository, the shared pool is closed by Shutdown
+func (r *lockRepository) Close() {}
+
+// Obtain takes the lock for ttl at most, in case its holder dies
+func (r *lockRepository) Obtain(name string, ttl time.Duration) (string, bool, error) {
+	token := uuid.New().String()
+	ok, err := r.client.SetNX(r.ctx, fmt.Sprintf("log:lock:%s", name), token, ttl).Result()
+	if err != nil || !ok {
+		return "", false, err
+	}
+
+	return token, true, nil
+}
+
+// Release frees the lock, a lock expired and taken by another is kept
+func (r *lockRepository) Release(name, token string) error {
+	return unlockScript.Run(r.ctx, r.client, []string{fmt.Sprintf("log:lock:%s", name)}, token).Err()
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"stream-session-api/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type nodeRepository struct {
+	client *redis.Client
+	ctx    context.Context
+}
+
+func NewNode(ctx context.Context) domain.NodeRepository {
+	return &nodeRepository{
+		client: redisClient(),
+		ctx:    ctx,
+	}
+}
+
+// Close releases the repository, the shared pool is closed by Shutdown
+func (r *nodeRepository) Close() {}
+
+func (r *nodeRepository) GetAll() ([]*domain.Node, error) {
+	var cursor uint64
+	var results []*domain.Node
+
+	for {
+		// Scan for matching keys
+		var keys []string
+		var err error
+		keys, cursor, err = r.client.Scan(r.ctx, cursor, "log:node:*", 0).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		// Fetch values for the keys
+		for _, key := range keys {
+			value, err := r.client.Get(r.ctx, key).Result()
+			if err != nil {
+				return nil, err
+			}
+
+			result := &domain.Node{}
+			if err := json.Unmarshal([]byte(value), result); err != nil {
+				return nil, err
+			}
+			results = append(results, result)
+		}
+
+		// Break if cursor is 0 (no more keys)
+		if cursor == 0 {
+			break
+		}
+	}
+
+	return results, nil
+}
+
+func (r *nodeRepository) FindByName(name string) *domain.Node {
+	value, err := r.client.Get(r.ctx, fmt.Sprintf("log:node:%s", name)).Result()
+	if err != nil {
+		return nil
+	}
+
+	var result *domain.Node
+	if err := json.Unmarshal([]byte(value), &result); err != nil {
+		return nil
+	}
+
+	return result
+}
+
+func (r *nodeRepository) Save(node *domain.Node) error {
+	json, err := json.Marshal(node)
+	if err != nil {
+		return err
+	}
+
+	return r.client.Set(r.ctx, fmt.Sprintf("log:node:%s", node.Name), json, 0).Err()
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"stream-session-api/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// releaseScript decrements a reference count and deletes it at zero atomically
+var releaseScript = redis.NewScript(`
+local count = redis.call("DECR", KEYS[1])
+if count <= 0 then
+	redis.call("DEL", KEYS[1])
+end
+return count
+`)
+
+type originRepository struct {
+	client *redis.Client
+	ctx    context.Context
+}
+
+func NewOrigin(ctx context.Context) domain.OriginRepository {
+	return &originRepository{
+		client: redisClient(),
+		ctx:    ctx,
+	}
+}
+
+// Close releases the repository, the shared pool is closed by Shutdown
+func (r *originRepository) Close() {}
+
+// Acquire increments the reference count of an origin path
+func (r *originRepository) Acquire(node, streamId string) (int64, error) {
+	return r.client.Incr(r.ctx, fmt.Sprintf("log:origin:%s:%s", node, streamId)).Result()
+}
+
+// Release decrements the reference count of an origin path,
+// the count is deleted once it drops to zero.
+func (r *originRepository) Release(node, streamId string) (int64, error) {
+	key := fmt.Sprintf("log:origin:%s:%s", node, streamId)
+	return releaseScript.Run(r.ctx, r.client, []string{key}).Int64()
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"stream-session-api/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type publishRepository struct {
+	client *redis.Client
+	ctx    context.Context
+}
+
+func NewPublish(ctx context.Context) domain.PublishRepository {
+	return &publishRepository{
+		client: redisClient(),
+		ctx:    ctx,
+	}
+}
+
+// Close releases the repository, the shared pool is closed by Shutdown
+func (r *publishRepository) Close() {}
+
+func (r *publishRepository) GetAll() ([]*domain.Publish, error) {
+	var cursor uint64
+	var results []*domain.Publish
+
+	for {
+		// Scan for matching keys
+		var keys []string
+		var err error
+		keys, cursor, err = r.client.Scan(r.ctx, cursor, "log:publish:*", 0).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		// Fetch values for the keys
+		for _, key := range keys {
+			value, err := r.client.Get(r.ctx, key).Result()
+			if err != nil {
+				return nil, err
+			}
+
+			result := &domain.Publish{}
+			if err := json.Unmarshal([]byte(value), result); err != nil {
+				return nil, err
+			}
+			results = append(results, result)
+		}
+
+		// Break if cursor is 0 (no more keys)
+		if cursor == 0 {
+			break
+		}
+	}
+
+	return results, nil
+}
+
+func (r *publishRepository) FindByUuid(uuid string) *domain.Publish {
+	value, err := r.client.Get(r.ctx, fmt.Sprintf("log:publish:%s", uuid)).Result()
+	if err != nil {
+		return nil
+	}
+
+	var result *domain.Publish
+	if err := json.Unmarshal([]byte(value), &result); err != nil {
+		return nil
+	}
+
+	return result
+}
+
+func (r *publishRepository) Insert(publish *domain.Publish) error {
+	json, err := json.Marshal(publish)
+	if err != nil {
+		return err
+	}
+
+	return r.client.Set(r.ctx, fmt.Sprintf("log:publish:%s", publish.Uuid), json, 0).Err()
+}
+
+func (r *publishRepository) Delete(uuid string) error {
+	return r.client.Del(r.ctx, fmt.Sprintf("log:publish:%s", uuid), fmt.Sprintf("log:used:%s", uuid)).Err()
+}
+
+func (r *publishRepository) Consume(uuid, address string) (bool, error) {
+	key := fmt.Sprintf("log:used:%s", uuid)
+
+	// First use binds the credential to the publisher address
+	first, err := r.client.SetNX(r.ctx, key, address, 0).Result()
+	if err != nil || first {
+		return first, err
+	}
+
+	// Later requests of the same publisher connection
+	used, err := r.client.Get(r.ctx, key).Result()
+	if err != nil {
+		return false, err
+	}
+	return used == address, nil
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"stream-session-api/domain"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type recordingRepository struct {
+	client *redis.Client
+	ctx    context.Context
+}
+
+func NewRecording(ctx context.Context) domain.RecordingRepository {
+	return &recordingRepository{
+		client: redisClient(),
+		ctx:    ctx,
+	}
+}
+
+// Close releases the repository, the shared pool is closed by Shutdown
+func (r *recordingRepository) Close() {}
+
+func (r *recordingRepository) GetAll() ([]*domain.Recording, error) {
+	var cursor uint64
+	var results []*domain.Recording
+
+	for {
+		// Scan for matching keys
+		var keys []string
+		var err error
+		keys, cursor, err = r.client.Scan(r.ctx, cursor, "log:recording:*", 0).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		// Fetch values for the keys, a key may be deleted meanwhile
+		for _, key := range keys {
+			value, err := r.client.Get(r.ctx, key).Result()
+			if err == redis.Nil {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			result := &domain.Recording{}
+			if err := json.Unmarshal([]byte(value), result); err != nil {
+				return nil, err
+			}
+			results = append(results, result)
+		}
+
+		// Break if cursor is 0 (no more keys)
+		if cursor == 0 {
+			break
+		}
+	}
+
+	return results, nil
+}
+
+func (r *recordingRepository) FindById(id string) *domain.Recording {
+	value, err := r.client.Get(r.ctx, fmt.Sprintf("log:recording:%s", id)).Result()
+	if err != nil {
+		return nil
+	}
+
+	var result *domain.Recording
+	if err := json.Unmarshal([]byte(value), &result); err != nil {
+		return nil
+	}
+	if result.Id == id {
+		return result
+	}
+
+	return nil
+}
+
+func (r *recordingRepository) Insert(recording *domain.Recording) error {
+	json, err := json.Marshal(recording)
+	if err != nil {
+		return err
+	}
+
+	return r.client.Set(r.ctx, fmt.Sprintf("log:recording:%s", recording.Id), json, 0).Err()
+}
+
+func (r *recordingRepository) Delete(id string) error {
+	return r.client.Del(r.ctx, fmt.Sprintf("log:recording:%s", id)).Err()
+}
+
+// ClaimStop reserves the stop of a recording, false when another stop runs.
+// The claim expires in case its holder dies.
+func (r *recordingRepository) ClaimStop(id string) (bool, error) {
+	return r.client.SetNX(r.ctx, fmt.Sprintf("log:recording-stop:%s", id), 1, time.Minute).Result()
+}
+
+func (r *recordingRepository) ReleaseStop(id string) error {
+	return r.client.Del(r.ctx, fmt.Sprintf("log:recording-stop:%s", id)).Err()
+}
+
+func (r *recordingRepository) SaveReport(report *domain.RetentionReport) error {
+	json, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	return r.client.Set(r.ctx, "log:retention:report", json, 0).Err()
+}
+
+func (r *recordingRepository) LastReport() *domain.RetentionReport {
+	value, err := r.client.Get(r.ctx, "log:retention:report").Result()
+	if err != nil {
+		return nil
+	}
+
+	var result *domain.RetentionReport
+	if err := json.Unmarshal([]byte(value), &result); err != nil {
+		return nil
+	}
+
+	return result
+}
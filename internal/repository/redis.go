@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"fmt"
+	"stream-session-api/internal/conf/network"
+	"stream-session-api/pkg"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/extra/redisotel/v9"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	rdb     *redis.Client
+	rdbOnce sync.Once
+)
+
+// redisClient returns the redis pool shared by every repository
+func redisClient() *redis.Client {
+	rdbOnce.Do(func() {
+		addr := fmt.Sprintf("%s:%d", network.Get().Redis.Ip, network.Get().Redis.Port)
+		password := network.Get().Redis.Password
+		db := network.Get().Redis.DatabaseIndex
+
+		rdb = redis.NewClient(&redis.Options{
+			Addr:         addr,
+			Password:     password, // no password set
+			DB:           int(db),
+			DialTimeout:  5 * time.Second, // Wait to conenct
+			ReadTimeout:  5 * time.Second, // Wait to read
+			WriteTimeout: 5 * time.Second, // Wait to get
+		})
+
+		// Trace redis commands
+		if err := redisotel.InstrumentTracing(rdb); err != nil {
+			pkg.LogWarn(fmt.Sprintf("failed to instrument redis tracing: %v", err))
+		}
+	})
+	return rdb
+}
+
+// Shutdown closes the shared redis pool
+func Shutdown() error {
+	if rdb == nil {
+		return nil
+	}
+	return rdb.Close()
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"stream-session-api/domain"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type snapshotRepository struct {
+	client *redis.Client
+	ctx    context.Context
+}
+
+func NewSnapshot(ctx context.Context) domain.SnapshotRepository {
+	return &snapshotRepository{
+		client: redisClient(),
+		ctx:    ctx,
+	}
+}
+
+// Close releases the repository, the shared pool is closed by Shutdown
+func (r *snapshotRepository) Close() {}
+
+func (r *snapshotRepository) Find(streamId string, width, height uint) *domain.Snapshot {
+	value, err := r.client.Get(r.ctx, fmt.Sprintf("log:snapshot:%s:%dx%d", streamId, width, height)).Result()
+	if err != nil {
+		return nil
+	}
+
+	var result *domain.Snapshot
+	if err := json.Unmarshal([]byte(value), &result); err != nil {
+		return nil
+	}
+
+	return result
+}
+
+func (r *snapshotRepository) Save(snapshot *domain.Snapshot, ttl time.Duration) error {
+	json, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	return r.client.Set(r.ctx, fmt.Sprintf("log:snapshot:%s:%dx%d", snapshot.StreamId, snapshot.Width, snapshot.Height), json, ttl).Err()
+}
@@ -5,8 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"stream-session-api/domain"
-	"stream-session-api/internal/conf/network"
-	"time"
 
 	"github.com/redis/go-redis/v9"
 )
@@ -16,29 +14,15 @@ type streamRepository struct {
 	ctx    context.Context
 }
 
-func NewStream() domain.StreamRepository {
-	addr := fmt.Sprintf("%s:%d", network.Get().Redis.Ip, network.Get().Redis.Port)
-	password := network.Get().Redis.Password
-	db := network.Get().Redis.DatabaseIndex
-
-	rdb := redis.NewClient(&redis.Options{
-		Addr:         addr,
-		Password:     password, // no password set
-		DB:           int(db),
-		DialTimeout:  5 * time.Second, // Wait to conenct
-		ReadTimeout:  5 * time.Second, // Wait to read
-		WriteTimeout: 5 * time.Second, // Wait to get
-	})
-
+func NewStream(ctx context.Context) domain.StreamRepository {
 	return &streamRepository{
-		client: rdb,
-		ctx:    context.Background(),
+		client: redisClient(),
+		ctx:    ctx,
 	}
 }
 
-func (r *streamRepository) Close() {
-	r.client.Close()
-}
+// Close releases the repository, the shared pool is closed by Shutdown
+func (r *streamRepository) Close() {}
 
 func (r *streamRepository) GetAll() ([]*domain.Stream, error) {
 	var cursor uint64
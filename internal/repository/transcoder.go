@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"stream-session-api/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type transcoderRepository struct {
+	client *redis.Client
+	ctx    context.Context
+}
+
+func NewTranscoder(ctx context.Context) domain.TranscoderRepository {
+	return &transcoderRepository{
+		client: redisClient(),
+		ctx:    ctx,
+	}
+}
+
+// Close releases the repository, the shared pool is closed by Shutdown
+func (r *transcoderRepository) Close() {}
+
+func (r *transcoderRepository) GetAll() ([]*domain.Transcoder, error) {
+	var cursor uint64
+	var results []*domain.Transcoder
+
+	for {
+		// Scan for matching keys
+		var keys []string
+		var err error
+		keys, cursor, err = r.client.Scan(r.ctx, cursor, "log:transcoder:*", 0).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		// Fetch values for the keys
+		for _, key := range keys {
+			value, err := r.client.Get(r.ctx, key).Result()
+			if err != nil {
+				return nil, err
+			}
+
+			result := &domain.Transcoder{}
+			if err := json.Unmarshal([]byte(value), result); err != nil {
+				return nil, err
+			}
+			results = append(results, result)
+		}
+
+		// Break if cursor is 0 (no more keys)
+		if cursor == 0 {
+			break
+		}
+	}
+
+	return results, nil
+}
+
+func (r *transcoderRepository) FindByPath(node, path string) *domain.Transcoder {
+	value, err := r.client.Get(r.ctx, fmt.Sprintf("log:transcoder:%s:%s", node, path)).Result()
+	if err != nil {
+		return nil
+	}
+
+	var result *domain.Transcoder
+	if err := json.Unmarshal([]byte(value), &result); err != nil {
+		return nil
+	}
+
+	return result
+}
+
+func (r *transcoderRepository) Insert(transcoder *domain.Transcoder) error {
+	json, err := json.Marshal(transcoder)
+	if err != nil {
+		return err
+	}
+
+	return r.client.Set(r.ctx, fmt.Sprintf("log:transcoder:%s:%s", transcoder.Node, transcoder.Path), json, 0).Err()
+}
+
+func (r *transcoderRepository) Delete(node, path string) error {
+	return r.client.Del(r.ctx, fmt.Sprintf("log:transcoder:%s:%s", node, path)).Err()
+}
+
+// Acquire increments the reference count of a transcoder
+func (r *transcoderRepository) Acquire(node, path string) (int64, error) {
+	return r.client.Incr(r.ctx, fmt.Sprintf("log:transcoder-refs:%s:%s", node, path)).Result()
+}
+
+// Release decrements the reference count of a transcoder,
+// the count is deleted once it drops to zero.
+func (r *transcoderRepository) Release(node, path string) (int64, error) {
+	key := fmt.Sprintf("log:transcoder-refs:%s:%s", node, path)
+	return releaseScript.Run(r.ctx, r.client, []string{key}).Int64()
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"stream-session-api/domain"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type viewerRepository struct {
+	client *redis.Client
+	ctx    context.Context
+}
+
+func NewViewer(ctx context.Context) domain.ViewerRepository {
+	return &viewerRepository{
+		client: redisClient(),
+		ctx:    ctx,
+	}
+}
+
+// Close releases the repository, the shared pool is closed by Shutdown
+func (r *viewerRepository) Close() {}
+
+func (r *viewerRepository) GetAll() ([]*domain.Viewer, error) {
+	var cursor uint64
+	var results []*domain.Viewer
+
+	for {
+		// Scan for matching keys
+		var keys []string
+		var err error
+		keys, cursor, err = r.client.Scan(r.ctx, cursor, "log:viewer:*", 0).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		// Fetch values for the keys, a key may expire meanwhile
+		for _, key := range keys {
+			value, err := r.client.Get(r.ctx, key).Result()
+			if err == redis.Nil {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			result := &domain.Viewer{}
+			if err := json.Unmarshal([]byte(value), result); err != nil {
+				return nil, err
+			}
+			results = append(results, result)
+		}
+
+		// Break if cursor is 0 (no more keys)
+		if cursor == 0 {
+			break
+		}
+	}
+
+	return results, nil
+}
+
+func (r *viewerRepository) Save(viewer *domain.Viewer, ttl time.Duration) error {
+	json, err := json.Marshal(viewer)
+	if err != nil {
+		return err
+	}
+
+	return r.client.Set(r.ctx, fmt.Sprintf("log:viewer:%s:%s", viewer.Stream, viewer.Id), json, ttl).Err()
+}
+
+func (r *viewerRepository) Delete(stream, id string) error {
+	return r.client.Del(r.ctx, fmt.Sprintf("log:viewer:%s:%s", stream, id)).Err()
+}
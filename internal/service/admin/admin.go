@@ -0,0 +1,171 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"stream-session-api/domain"
+	"stream-session-api/internal/media"
+	"stream-session-api/internal/repository"
+	pb "stream-session-api/internal/service/admin/proto"
+	"stream-session-api/internal/session"
+	"stream-session-api/pkg"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type Server struct {
+	pb.AdminServiceServer
+}
+
+// setNodeState saves the state of a known media node
+func setNodeState(ctx context.Context, name, state string) error {
+	known := false
+	for _, node := range media.Nodes() {
+		if node == name {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return status.Errorf(codes.NotFound, "media node %s not found", name)
+	}
+
+	repo := repository.NewNode(ctx)
+	defer repo.Close()
+
+	if err := repo.Save(&domain.Node{Name: name, State: state}); err != nil {
+		pkg.LogErrorContext(ctx, err)
+		return status.Errorf(codes.Unknown, "failed to save media node")
+	}
+	pkg.LogInfoContext(ctx, fmt.Sprintf("media node %s %s", name, state))
+
+	return nil
+}
+
+func (*Server) DrainNode(ctx context.Context, in *pb.NodeRequest) (*emptypb.Empty, error) {
+	// Check value pb.NodeRequest
+	if in == nil {
+		pkg.LogErrorContext(ctx, "invalid message request")
+		return nil, status.Errorf(codes.InvalidArgument, "invalid message request")
+	}
+
+	// Get the peer information from the context
+	client, _ := peer.FromContext(ctx)
+	pkg.LogInfoContext(ctx, fmt.Sprintf("drain media node %s requested from %s", in.GetNode(), client.Addr))
+
+	if err := setNodeState(ctx, in.GetNode(), domain.NodeDraining); err != nil {
+		return nil, err
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (*Server) ActivateNode(ctx context.Context, in *pb.NodeRequest) (*emptypb.Empty, error) {
+	// Check value pb.NodeRequest
+	if in == nil {
+		pkg.LogErrorContext(ctx, "invalid message request")
+		return nil, status.Errorf(codes.InvalidArgument, "invalid message request")
+	}
+
+	// Get the peer information from the context
+	client, _ := peer.FromContext(ctx)
+	pkg.LogInfoContext(ctx, fmt.Sprintf("activate media node %s requested from %s", in.GetNode(), client.Addr))
+
+	if err := setNodeState(ctx, in.GetNode(), domain.NodeActive); err != nil {
+		return nil, err
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (*Server) MigrateNode(in *pb.NodeRequest, srv pb.AdminService_MigrateNodeServer) error {
+	ctx := srv.Context()
+
+	// Check value pb.NodeRequest
+	if in == nil {
+		pkg.LogErrorContext(ctx, "invalid message request")
+		return status.Errorf(codes.InvalidArgument, "invalid message request")
+	}
+
+	// Get the peer information from the context
+	client, _ := peer.FromContext(ctx)
+	pkg.LogInfoContext(ctx, fmt.Sprintf("migrate media node %s requested from %s", in.GetNode(), client.Addr))
+
+	// No new placement while migrating
+	if err := setNodeState(ctx, in.GetNode(), domain.NodeDraining); err != nil {
+		return err
+	}
+
+	repo := repository.NewStream(ctx)
+	defer repo.Close()
+
+	all, err := repo.GetAll()
+	if err != nil {
+		pkg.LogErrorContext(ctx, err)
+		return status.Errorf(codes.Unknown, "failed to get stream sessions")
+	}
+
+	// Stream sessions on the node
+	var streams []*domain.Stream
+	for _, stream := range all {
+		if media.NodeName(stream.Node) == in.GetNode() {
+			streams = append(streams, stream)
+		}
+	}
+
+	progress := &pb.MigrateNodeProgress{
+		Node:  in.GetNode(),
+		Total: int32(len(streams)),
+	}
+	if err := srv.Send(progress); err != nil {
+		return err
+	}
+
+	exclude := map[string]bool{in.GetNode(): true}
+	for _, stream := range streams {
+		if ctx.Err() != nil {
+			return status.Errorf(codes.Canceled, "migration cancelled")
+		}
+
+		progress.StreamId = stream.Id
+		progress.OldUrl = session.PlaybackURL(stream)
+		progress.NewUrl = ""
+		progress.TargetNode = ""
+		progress.Error = ""
+
+		migrated, err := session.Migrate(ctx, stream, exclude)
+		switch {
+		case migrated == nil:
+			progress.Failed++
+			progress.Error = err.Error()
+			pkg.LogWarnContext(ctx, fmt.Sprintf("failed to migrate %v: %v", *stream, err))
+		default:
+			progress.Migrated++
+			progress.NewUrl = session.PlaybackURL(migrated)
+			progress.TargetNode = migrated.Node
+			if err != nil {
+				// Migrated but the old path is left behind
+				progress.Error = err.Error()
+				pkg.LogWarnContext(ctx, fmt.Sprintf("failed to remove %v: %v", *stream, err))
+			}
+		}
+
+		if err := srv.Send(progress); err != nil {
+			return err
+		}
+	}
+
+	// Remove the node from the pool once empty
+	progress.StreamId, progress.OldUrl, progress.NewUrl, progress.TargetNode, progress.Error = "", "", "", "", ""
+	progress.Done = true
+	if progress.Failed == 0 {
+		if err := setNodeState(ctx, in.GetNode(), domain.NodeRemoved); err != nil {
+			return err
+		}
+	}
+
+	return srv.Send(progress)
+}
@@ -0,0 +1,54 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"stream-session-api/internal/conf/network"
+	pb "stream-session-api/internal/service/admin/proto"
+	"stream-session-api/pkg"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryInterceptor refuses the unary AdminService rpcs without the admin token
+func UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := authorize(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// StreamInterceptor refuses the streaming AdminService rpcs without the admin token
+func StreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := authorize(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// authorize checks the bearer token of the admin rpcs, an empty admin token
+// refuses every one. Other services are left to their handlers.
+func authorize(ctx context.Context, method string) error {
+	if !strings.HasPrefix(method, "/"+pb.AdminService_ServiceDesc.ServiceName+"/") {
+		return nil
+	}
+
+	token := network.Get().Grpc.AdminToken
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		bearer, ok := strings.CutPrefix(value, "Bearer ")
+		if token != "" && ok && subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) == 1 {
+			return nil
+		}
+	}
+
+	client, _ := peer.FromContext(ctx)
+	pkg.LogWarnContext(ctx, fmt.Sprintf("admin rpc %s from %s refused", method, client.Addr))
+	return status.Errorf(codes.Unauthenticated, "invalid admin token")
+}
@@ -0,0 +1,70 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"stream-session-api/domain"
+	"stream-session-api/internal/repository"
+	pb "stream-session-api/internal/service/admin/proto"
+	"stream-session-api/internal/session"
+	"stream-session-api/pkg"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (*Server) SetLegalHold(ctx context.Context, in *pb.LegalHoldRequest) (*emptypb.Empty, error) {
+	// Check value pb.LegalHoldRequest
+	if in == nil || in.GetRecordingId() == "" || in.GetHold() && in.GetPrincipal() == "" {
+		pkg.LogErrorContext(ctx, "invalid message request")
+		return nil, status.Errorf(codes.InvalidArgument, "invalid message request")
+	}
+
+	// Get the peer information from the context
+	client, _ := peer.FromContext(ctx)
+	pkg.LogInfoContext(ctx, fmt.Sprintf("legal hold %t of recording %s by %s (%s) requested from %s",
+		in.GetHold(), in.GetRecordingId(), in.GetPrincipal(), in.GetReason(), client.Addr))
+
+	var hold *domain.LegalHold
+	if in.GetHold() {
+		hold = &domain.LegalHold{Reason: in.GetReason(), Principal: in.GetPrincipal(), At: time.Now()}
+	}
+	if err := session.SetLegalHold(ctx, in.GetRecordingId(), hold); err != nil {
+		pkg.LogErrorContext(ctx, err)
+		switch {
+		case errors.Is(err, pkg.ErrNotFound):
+			return nil, status.Errorf(codes.NotFound, "recording with specified id not found")
+		case errors.Is(err, pkg.ErrBadRequest):
+			return nil, status.Errorf(codes.FailedPrecondition, "recording already purged")
+		default:
+			return nil, status.Errorf(codes.Unknown, "failed to save legal hold")
+		}
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (*Server) GetRetentionReport(ctx context.Context, _ *emptypb.Empty) (*pb.RetentionReport, error) {
+	repo := repository.NewRecording(ctx)
+	defer repo.Close()
+
+	report := repo.LastReport()
+	if report == nil {
+		return nil, status.Errorf(codes.NotFound, "retention did not run yet")
+	}
+
+	return &pb.RetentionReport{
+		StartedAt:  timestamppb.New(report.StartedAt),
+		FinishedAt: timestamppb.New(report.FinishedAt),
+		Recordings: int32(report.Recordings),
+		Held:       int32(report.Held),
+		Segments:   int32(report.Segments),
+		Bytes:      report.Bytes,
+		Errors:     report.Errors,
+	}, nil
+}
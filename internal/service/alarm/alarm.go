@@ -0,0 +1,73 @@
+package alarm
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"stream-session-api/domain"
+	"stream-session-api/internal/conf/network"
+	"stream-session-api/internal/session"
+	"stream-session-api/pkg"
+	"strings"
+)
+
+// response lists the recordings started or extended by an event
+type response struct {
+	Recordings []string `json:"recordings"`
+}
+
+// Register adds the webhook route of the alarm systems
+func Register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /alarms", handle)
+}
+
+// handle records the cameras mapped to the source of an event
+func handle(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Bearer token of the alarms config, empty refuses every event
+	token := network.Get().Alarms.Token
+	bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" || !ok || subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+		pkg.LogWarnContext(ctx, fmt.Sprintf("alarm event from %s refused", r.RemoteAddr))
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var event domain.AlarmEvent
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil || event.Source == "" || event.Type == "" {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	pkg.LogInfoContext(ctx, fmt.Sprintf("alarm %s of %s at %s from %s", event.Type, event.Source, event.Time, r.RemoteAddr))
+
+	recordings, err := session.TriggerAlarm(ctx, &event)
+	if err != nil && len(recordings) == 0 {
+		pkg.LogErrorContext(ctx, err)
+		switch {
+		case errors.Is(err, pkg.ErrNotFound):
+			http.Error(w, "alarm source not found", http.StatusNotFound)
+		case errors.Is(err, pkg.ErrBadRequest):
+			http.Error(w, "recording not supported", http.StatusConflict)
+		default:
+			http.Error(w, "failed to record", http.StatusBadGateway)
+		}
+		return
+	}
+	// Cameras recorded before the failure are kept
+	if err != nil {
+		pkg.LogWarnContext(ctx, fmt.Sprintf("alarm %s of %s partly recorded: %v", event.Type, event.Source, err))
+	}
+
+	resp := response{}
+	for _, recording := range recordings {
+		resp.Recordings = append(resp.Recordings, recording.Id)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(resp)
+}
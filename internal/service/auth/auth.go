@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"stream-session-api/internal/conf/network"
+	"stream-session-api/internal/session"
+	"stream-session-api/pkg"
+	"sync"
+)
+
+// request is the body MediaMTX posts to its http authentication endpoint
+type request struct {
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Token    string `json:"token"`
+	Ip       string `json:"ip"`
+	Action   string `json:"action"` // publish, read, playback, api, metrics, pprof
+	Path     string `json:"path"`
+	Protocol string `json:"protocol"`
+	Id       string `json:"id"`
+	Query    string `json:"query"`
+}
+
+var (
+	hostOnce  sync.Once
+	hostAddrs []net.IP // Addresses of the dynastream host
+)
+
+// Register adds the MediaMTX authentication route, set as authHTTPAddress
+func Register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /mediamtx/auth", handle)
+}
+
+// handle checks every MediaMTX action, anything not allowed is refused:
+// publish needs the credential of a publish session, read and playback a
+// live session path unless they come from a trusted address, and the api,
+// metrics and pprof only answer dynastream.
+func handle(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var in request
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	switch in.Action {
+	case "publish":
+		err = authorizePublish(ctx, in)
+	case "read", "playback":
+		if !local(in.Ip) && !mediaNode(ctx, in.Ip) {
+			err = session.AuthorizeRead(ctx, in.Path)
+		}
+	case "api", "metrics", "pprof":
+		if !local(in.Ip) {
+			err = pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("%s only served to dynastream", in.Action))
+		}
+	default:
+		err = pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("unknown action %q", in.Action))
+	}
+
+	if err != nil {
+		pkg.LogWarnContext(ctx, fmt.Sprintf("%s %s from %s to %s refused: %v", in.Protocol, in.Action, in.Ip, in.Path, err))
+		if errors.Is(err, pkg.ErrProcessFail) {
+			http.Error(w, "failed to check credential", http.StatusInternalServerError)
+		} else {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		}
+		return
+	}
+
+	if in.Action == "publish" {
+		pkg.LogInfoContext(ctx, fmt.Sprintf("%s publish from %s to %s allowed", in.Protocol, in.Ip, in.Path))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authorizePublish allows a publisher holding the credential of a publish session
+func authorizePublish(ctx context.Context, in request) error {
+	// Credential as password (rtmp, srt) or token query (whip)
+	credential := in.Password
+	if credential == "" {
+		credential = in.Token
+	}
+	if query, err := url.ParseQuery(in.Query); credential == "" && err == nil {
+		credential = query.Get("token")
+	}
+
+	// Transcoders republish from the loopback of their media node
+	if session.IsTranscodePath(in.Path) {
+		return session.AuthorizeTranscoder(ctx, in.Path, in.Ip)
+	}
+	return session.AuthorizePublish(ctx, in.Path, credential, in.Ip)
+}
+
+// local reports whether address is a loopback or an address of the
+// dynastream host
+func local(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	if ip.IsLoopback() {
+		return true
+	}
+
+	hostOnce.Do(func() {
+		addrs, err := net.InterfaceAddrs()
+		if err != nil {
+			pkg.LogWarn(fmt.Sprintf("failed to list host addresses: %v", err))
+			return
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok {
+				hostAddrs = append(hostAddrs, ipNet.IP)
+			}
+		}
+	})
+	for _, host := range hostAddrs {
+		if host.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// mediaNode reports whether address is an address of a configured media
+// node, e.g. an edge relaying an origin path
+func mediaNode(ctx context.Context, address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+
+	for _, node := range network.Get().MediaMtx {
+		hosts := []string{node.Http.Ip, node.Rtsp.Ip, node.WebRtc.Ip, node.Hls.Ip, node.LlHls.Ip,
+			node.Rtsps.Ip, node.Srt.Ip, node.Rtmp.Ip, node.Playback.Ip}
+		for _, host := range hosts {
+			if host == "" {
+				continue
+			}
+			if nodeIp := net.ParseIP(host); nodeIp != nil {
+				if nodeIp.Equal(ip) {
+					return true
+				}
+				continue
+			}
+			// Host names of the node
+			addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+			if err != nil {
+				continue
+			}
+			for _, addr := range addrs {
+				if addr.IP.Equal(ip) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+package hls
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"regexp"
+	"strconv"
+	"stream-session-api/domain"
+	"stream-session-api/internal/conf/network"
+	"stream-session-api/internal/media"
+	"stream-session-api/internal/session"
+	"stream-session-api/pkg"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// uriAttribute matches the URI attribute of a playlist tag
+var uriAttribute = regexp.MustCompile(`URI="([^"]*)"`)
+
+// Register adds the hls routes, playlists, segments and parts of a session
+func Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /{uuid}/{file...}", handle)
+}
+
+// URL returns the hls proxy url of a stream session
+func URL(stream *domain.Stream) string {
+	return fmt.Sprintf("%s/%s/index.m3u8?token=%s", network.Get().Http.BaseUrl(), stream.Uuid, stream.Token)
+}
+
+// handle authenticates the session token and forwards the request to the
+// hls server of the media node hosting the stream session
+func handle(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	token := pkg.RequestToken(r)
+	stream, err := session.Authorize(ctx, r.PathValue("uuid"), token)
+	if err != nil {
+		pkg.LogWarnContext(ctx, fmt.Sprintf("hls %s refused: %v", r.URL.Path, err))
+		if errors.Is(err, pkg.ErrNotFound) {
+			http.Error(w, "stream session not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+		}
+		return
+	}
+
+	// Only MediaMTX nodes are proxied
+	if backend := network.Get().Media; backend != "" && backend != media.BackendMediaMtx {
+		http.Error(w, "hls not supported by the media backend", http.StatusNotImplemented)
+		return
+	}
+	node := network.Get().Node(stream.Node)
+	server := node.Hls
+	if server.Port == 0 {
+		server = node.LlHls
+	}
+	if server.Port == 0 {
+		http.Error(w, "hls not served by the media node", http.StatusNotImplemented)
+		return
+	}
+
+	playlist := strings.HasSuffix(r.PathValue("file"), ".m3u8")
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL.Scheme = "http"
+			pr.Out.URL.Host = net.JoinHostPort(server.Ip, strconv.FormatUint(uint64(server.Port), 10))
+
+			// Keep the low-latency directives, drop the token
+			query := pr.In.URL.Query()
+			query.Del("token")
+			pr.Out.URL.RawQuery = query.Encode()
+
+			pr.Out.Host = ""
+			pr.Out.Header.Del("Authorization")
+			pr.SetXForwarded()
+
+			// Playlists are rewritten, keep them uncompressed
+			if playlist {
+				pr.Out.Header.Del("Accept-Encoding")
+			}
+		},
+		Transport: otelhttp.NewTransport(http.DefaultTransport),
+		ModifyResponse: func(resp *http.Response) error {
+			if !playlist || resp.StatusCode != http.StatusOK {
+				return nil
+			}
+
+			// Playlists are fetched all along the playback
+			address := pkg.RequestAddress(r, network.Get().Http.Trusted)
+			err := session.TouchViewer(ctx, &domain.Viewer{
+				Id:       "hls-" + address,
+				Stream:   stream.Uuid,
+				Owner:    stream.Owner,
+				Node:     stream.Node,
+				Protocol: domain.ProtocolHls,
+				Address:  address,
+			})
+			if err != nil {
+				pkg.LogWarnContext(ctx, fmt.Sprintf("failed to track hls viewer of %s: %v", stream.Uuid, err))
+			}
+
+			return rewritePlaylist(resp, token)
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			pkg.LogErrorContext(r.Context(), fmt.Sprintf("hls %s to media node %s failed: %v", r.URL.Path, node.Name, err))
+			http.Error(w, "media node unavailable", http.StatusBadGateway)
+		},
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+// rewritePlaylist adds the token to every uri of a playlist
+func rewritePlaylist(resp *http.Response, token string) error {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#"):
+			// EXT-X-MAP, EXT-X-PART, EXT-X-PRELOAD-HINT, EXT-X-MEDIA...
+			line = uriAttribute.ReplaceAllStringFunc(line, func(attr string) string {
+				uri := uriAttribute.FindStringSubmatch(attr)[1]
+				return `URI="` + withToken(uri, token) + `"`
+			})
+		default:
+			line = withToken(line, token)
+		}
+		out.WriteString(line)
+		out.WriteString("\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	resp.Body = io.NopCloser(&out)
+	resp.ContentLength = int64(out.Len())
+	resp.Header.Set("Content-Length", strconv.Itoa(out.Len()))
+
+	return nil
+}
+
+// withToken adds the token query to a playlist uri
+func withToken(uri, token string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+
+	query := u.Query()
+	query.Set("token", token)
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
@@ -0,0 +1,84 @@
+package recording
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"path/filepath"
+	"stream-session-api/internal/media"
+	"stream-session-api/internal/session"
+	"stream-session-api/pkg"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// Register adds the signed playback and the clip download routes
+func Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /recordings/{id}/get", playback)
+	mux.HandleFunc("GET /exports/{id}", download)
+	mux.HandleFunc("GET /exports/{id}/manifest", download)
+}
+
+// playback checks the signature of a playback url and forwards the request
+// to the playback server of the media node holding the recording
+func playback(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	recording, start, duration, err := session.VerifyRecordingURL(ctx, r.PathValue("id"), r.URL.Query())
+	if err != nil {
+		pkg.LogWarnContext(ctx, fmt.Sprintf("playback %s refused: %v", r.URL.Path, err))
+		if errors.Is(err, pkg.ErrNotFound) {
+			http.Error(w, "recording not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "invalid or expired url", http.StatusForbidden)
+		}
+		return
+	}
+
+	source := media.Node(recording.Node).BuildRecordURL(recording.Path, start, duration, "")
+	target, err := url.Parse(source)
+	if source == "" || err != nil {
+		http.Error(w, "playback not served by the media node", http.StatusNotImplemented)
+		return
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL = target
+			pr.Out.Host = ""
+			pr.SetXForwarded()
+		},
+		Transport: otelhttp.NewTransport(http.DefaultTransport),
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			pkg.LogErrorContext(r.Context(), fmt.Sprintf("playback of recording %s on media node %s failed: %v", recording.Id, recording.Node, err))
+			http.Error(w, "media node unavailable", http.StatusBadGateway)
+		},
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+// download serves an exported clip or its manifest to the holder of its token
+func download(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	export, err := session.AuthorizeExport(ctx, r.PathValue("id"), pkg.RequestToken(r))
+	if err != nil {
+		pkg.LogWarnContext(ctx, fmt.Sprintf("download %s refused: %v", r.URL.Path, err))
+		if errors.Is(err, pkg.ErrNotFound) {
+			http.Error(w, "export not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+		}
+		return
+	}
+
+	file := export.File
+	if strings.HasSuffix(r.URL.Path, "/manifest") {
+		file = export.Manifest
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(file)))
+	http.ServeFile(w, r, file)
+}
@@ -0,0 +1,49 @@
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"stream-session-api/internal/conf/network"
+	"stream-session-api/internal/session"
+	"stream-session-api/pkg"
+)
+
+// Register adds the signed snapshot route
+func Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /snapshots/{id}", handle)
+}
+
+// handle checks the signature of a snapshot url and serves the JPEG of the
+// current frame of the camera
+func handle(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	streamId := r.PathValue("id")
+
+	width, height, err := session.VerifySnapshotURL(streamId, r.URL.Query())
+	if err != nil {
+		pkg.LogWarnContext(ctx, fmt.Sprintf("snapshot %s refused: %v", r.URL.Path, err))
+		http.Error(w, "invalid or expired url", http.StatusForbidden)
+		return
+	}
+
+	snapshot, err := session.Snapshot(ctx, streamId, width, height)
+	if err != nil {
+		pkg.LogErrorContext(ctx, err)
+		if errors.Is(err, pkg.ErrUnavailable) {
+			http.Error(w, "camera unavailable", http.StatusGatewayTimeout)
+		} else {
+			http.Error(w, "failed to grab snapshot", http.StatusBadGateway)
+		}
+		return
+	}
+
+	// Browsers refresh the image once the cached one expires
+	ttl := network.Get().Snapshot.CacheTtl
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Content-Length", strconv.Itoa(len(snapshot.Image)))
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", ttl))
+	w.Header().Set("Last-Modified", snapshot.TakenAt.UTC().Format(http.TimeFormat))
+	w.Write(snapshot.Image)
+}
@@ -20,8 +20,17 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	StreamService_StartStream_FullMethodName = "/stream.StreamService/StartStream"
-	StreamService_StopStream_FullMethodName  = "/stream.StreamService/StopStream"
+	StreamService_StartStream_FullMethodName    = "/stream.StreamService/StartStream"
+	StreamService_StopStream_FullMethodName     = "/stream.StreamService/StopStream"
+	StreamService_StartPublish_FullMethodName   = "/stream.StreamService/StartPublish"
+	StreamService_StopPublish_FullMethodName    = "/stream.StreamService/StopPublish"
+	StreamService_StartRecording_FullMethodName = "/stream.StreamService/StartRecording"
+	StreamService_StopRecording_FullMethodName  = "/stream.StreamService/StopRecording"
+	StreamService_ListRecordings_FullMethodName = "/stream.StreamService/ListRecordings"
+	StreamService_GetPlaybackUrl_FullMethodName = "/stream.StreamService/GetPlaybackUrl"
+	StreamService_ExportClip_FullMethodName     = "/stream.StreamService/ExportClip"
+	StreamService_GetSnapshot_FullMethodName    = "/stream.StreamService/GetSnapshot"
+	StreamService_WatchEvents_FullMethodName    = "/stream.StreamService/WatchEvents"
 )
 
 // StreamServiceClient is the client API for StreamService service.
@@ -30,6 +39,15 @@ const (
 type StreamServiceClient interface {
 	StartStream(ctx context.Context, in *StartStreamRequest, opts ...grpc.CallOption) (*StartStreamResponse, error)
 	StopStream(ctx context.Context, in *StopStreamRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	StartPublish(ctx context.Context, in *StartPublishRequest, opts ...grpc.CallOption) (*StartPublishResponse, error)
+	StopPublish(ctx context.Context, in *StopPublishRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	StartRecording(ctx context.Context, in *StartRecordingRequest, opts ...grpc.CallOption) (*Recording, error)
+	StopRecording(ctx context.Context, in *StopRecordingRequest, opts ...grpc.CallOption) (*Recording, error)
+	ListRecordings(ctx context.Context, in *ListRecordingsRequest, opts ...grpc.CallOption) (*ListRecordingsResponse, error)
+	GetPlaybackUrl(ctx context.Context, in *GetPlaybackUrlRequest, opts ...grpc.CallOption) (*GetPlaybackUrlResponse, error)
+	ExportClip(ctx context.Context, in *ExportClipRequest, opts ...grpc.CallOption) (*ExportClipResponse, error)
+	GetSnapshot(ctx context.Context, in *GetSnapshotRequest, opts ...grpc.CallOption) (*GetSnapshotResponse, error)
+	WatchEvents(ctx context.Context, in *WatchEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StreamEvent], error)
 }
 
 type streamServiceClient struct {
@@ -60,12 +78,120 @@ func (c *streamServiceClient) StopStream(ctx context.Context, in *StopStreamRequ
 	return out, nil
 }
 
+func (c *streamServiceClient) StartPublish(ctx context.Context, in *StartPublishRequest, opts ...grpc.CallOption) (*StartPublishResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StartPublishResponse)
+	err := c.cc.Invoke(ctx, StreamService_StartPublish_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *streamServiceClient) StopPublish(ctx context.Context, in *StopPublishRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, StreamService_StopPublish_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *streamServiceClient) StartRecording(ctx context.Context, in *StartRecordingRequest, opts ...grpc.CallOption) (*Recording, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Recording)
+	err := c.cc.Invoke(ctx, StreamService_StartRecording_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *streamServiceClient) StopRecording(ctx context.Context, in *StopRecordingRequest, opts ...grpc.CallOption) (*Recording, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Recording)
+	err := c.cc.Invoke(ctx, StreamService_StopRecording_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *streamServiceClient) ListRecordings(ctx context.Context, in *ListRecordingsRequest, opts ...grpc.CallOption) (*ListRecordingsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListRecordingsResponse)
+	err := c.cc.Invoke(ctx, StreamService_ListRecordings_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *streamServiceClient) GetPlaybackUrl(ctx context.Context, in *GetPlaybackUrlRequest, opts ...grpc.CallOption) (*GetPlaybackUrlResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetPlaybackUrlResponse)
+	err := c.cc.Invoke(ctx, StreamService_GetPlaybackUrl_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *streamServiceClient) ExportClip(ctx context.Context, in *ExportClipRequest, opts ...grpc.CallOption) (*ExportClipResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExportClipResponse)
+	err := c.cc.Invoke(ctx, StreamService_ExportClip_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *streamServiceClient) GetSnapshot(ctx context.Context, in *GetSnapshotRequest, opts ...grpc.CallOption) (*GetSnapshotResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetSnapshotResponse)
+	err := c.cc.Invoke(ctx, StreamService_GetSnapshot_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *streamServiceClient) WatchEvents(ctx context.Context, in *WatchEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StreamEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &StreamService_ServiceDesc.Streams[0], StreamService_WatchEvents_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchEventsRequest, StreamEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type StreamService_WatchEventsClient = grpc.ServerStreamingClient[StreamEvent]
+
 // StreamServiceServer is the server API for StreamService service.
 // All implementations must embed UnimplementedStreamServiceServer
 // for forward compatibility.
 type StreamServiceServer interface {
 	StartStream(context.Context, *StartStreamRequest) (*StartStreamResponse, error)
 	StopStream(context.Context, *StopStreamRequest) (*emptypb.Empty, error)
+	StartPublish(context.Context, *StartPublishRequest) (*StartPublishResponse, error)
+	StopPublish(context.Context, *StopPublishRequest) (*emptypb.Empty, error)
+	StartRecording(context.Context, *StartRecordingRequest) (*Recording, error)
+	StopRecording(context.Context, *StopRecordingRequest) (*Recording, error)
+	ListRecordings(context.Context, *ListRecordingsRequest) (*ListRecordingsResponse, error)
+	GetPlaybackUrl(context.Context, *GetPlaybackUrlRequest) (*GetPlaybackUrlResponse, error)
+	ExportClip(context.Context, *ExportClipRequest) (*ExportClipResponse, error)
+	GetSnapshot(context.Context, *GetSnapshotRequest) (*GetSnapshotResponse, error)
+	WatchEvents(*WatchEventsRequest, grpc.ServerStreamingServer[StreamEvent]) error
 	mustEmbedUnimplementedStreamServiceServer()
 }
 
@@ -82,6 +208,33 @@ func (UnimplementedStreamServiceServer) StartStream(context.Context, *StartStrea
 func (UnimplementedStreamServiceServer) StopStream(context.Context, *StopStreamRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method StopStream not implemented")
 }
+func (UnimplementedStreamServiceServer) StartPublish(context.Context, *StartPublishRequest) (*StartPublishResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartPublish not implemented")
+}
+func (UnimplementedStreamServiceServer) StopPublish(context.Context, *StopPublishRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StopPublish not implemented")
+}
+func (UnimplementedStreamServiceServer) StartRecording(context.Context, *StartRecordingRequest) (*Recording, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartRecording not implemented")
+}
+func (UnimplementedStreamServiceServer) StopRecording(context.Context, *StopRecordingRequest) (*Recording, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StopRecording not implemented")
+}
+func (UnimplementedStreamServiceServer) ListRecordings(context.Context, *ListRecordingsRequest) (*ListRecordingsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRecordings not implemented")
+}
+func (UnimplementedStreamServiceServer) GetPlaybackUrl(context.Context, *GetPlaybackUrlRequest) (*GetPlaybackUrlResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPlaybackUrl not implemented")
+}
+func (UnimplementedStreamServiceServer) ExportClip(context.Context, *ExportClipRequest) (*ExportClipResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExportClip not implemented")
+}
+func (UnimplementedStreamServiceServer) GetSnapshot(context.Context, *GetSnapshotRequest) (*GetSnapshotResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSnapshot not implemented")
+}
+func (UnimplementedStreamServiceServer) WatchEvents(*WatchEventsRequest, grpc.ServerStreamingServer[StreamEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchEvents not implemented")
+}
 func (UnimplementedStreamServiceServer) mustEmbedUnimplementedStreamServiceServer() {}
 func (UnimplementedStreamServiceServer) testEmbeddedByValue()                       {}
 
@@ -139,6 +292,161 @@ func _StreamService_StopStream_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _StreamService_StartPublish_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartPublishRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StreamServiceServer).StartPublish(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StreamService_StartPublish_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StreamServiceServer).StartPublish(ctx, req.(*StartPublishRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StreamService_StopPublish_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StopPublishRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StreamServiceServer).StopPublish(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StreamService_StopPublish_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StreamServiceServer).StopPublish(ctx, req.(*StopPublishRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StreamService_StartRecording_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartRecordingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StreamServiceServer).StartRecording(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StreamService_StartRecording_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StreamServiceServer).StartRecording(ctx, req.(*StartRecordingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StreamService_StopRecording_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StopRecordingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StreamServiceServer).StopRecording(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StreamService_StopRecording_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StreamServiceServer).StopRecording(ctx, req.(*StopRecordingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StreamService_ListRecordings_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRecordingsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StreamServiceServer).ListRecordings(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StreamService_ListRecordings_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StreamServiceServer).ListRecordings(ctx, req.(*ListRecordingsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StreamService_GetPlaybackUrl_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPlaybackUrlRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StreamServiceServer).GetPlaybackUrl(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StreamService_GetPlaybackUrl_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StreamServiceServer).GetPlaybackUrl(ctx, req.(*GetPlaybackUrlRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StreamService_ExportClip_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExportClipRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StreamServiceServer).ExportClip(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StreamService_ExportClip_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StreamServiceServer).ExportClip(ctx, req.(*ExportClipRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StreamService_GetSnapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSnapshotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StreamServiceServer).GetSnapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StreamService_GetSnapshot_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StreamServiceServer).GetSnapshot(ctx, req.(*GetSnapshotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StreamService_WatchEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(StreamServiceServer).WatchEvents(m, &grpc.GenericServerStream[WatchEventsRequest, StreamEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type StreamService_WatchEventsServer = grpc.ServerStreamingServer[StreamEvent]
+
 // StreamService_ServiceDesc is the grpc.ServiceDesc for StreamService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -154,7 +462,45 @@ var StreamService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "StopStream",
 			Handler:    _StreamService_StopStream_Handler,
 		},
+		{
+			MethodName: "StartPublish",
+			Handler:    _StreamService_StartPublish_Handler,
+		},
+		{
+			MethodName: "StopPublish",
+			Handler:    _StreamService_StopPublish_Handler,
+		},
+		{
+			MethodName: "StartRecording",
+			Handler:    _StreamService_StartRecording_Handler,
+		},
+		{
+			MethodName: "StopRecording",
+			Handler:    _StreamService_StopRecording_Handler,
+		},
+		{
+			MethodName: "ListRecordings",
+			Handler:    _StreamService_ListRecordings_Handler,
+		},
+		{
+			MethodName: "GetPlaybackUrl",
+			Handler:    _StreamService_GetPlaybackUrl_Handler,
+		},
+		{
+			MethodName: "ExportClip",
+			Handler:    _StreamService_ExportClip_Handler,
+		},
+		{
+			MethodName: "GetSnapshot",
+			Handler:    _StreamService_GetSnapshot_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchEvents",
+			Handler:       _StreamService_WatchEvents_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "stream.proto",
 }
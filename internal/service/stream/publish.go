@@ -0,0 +1,82 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"stream-session-api/internal/media"
+	"stream-session-api/internal/repository"
+	pb "stream-session-api/internal/service/stream/proto"
+	"stream-session-api/internal/session"
+	"stream-session-api/internal/zone"
+	"stream-session-api/pkg"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func (*Server) StartPublish(ctx context.Context, in *pb.StartPublishRequest) (*pb.StartPublishResponse, error) {
+	// Check value pb.StartPublishRequest
+	if in == nil || in.GetUsername() == "" || in.GetStreamId() == "" {
+		pkg.LogErrorContext(ctx, "invalid message request")
+		return nil, status.Errorf(codes.InvalidArgument, "invalid message request")
+	}
+
+	// Get the peer information from the context
+	client, _ := peer.FromContext(ctx)
+	pkg.LogInfoContext(ctx, fmt.Sprintf("%s requested to start publish for %s from %s", in.GetUsername(), in.GetStreamId(), client.Addr))
+
+	// Create publish session
+	publish, err := session.CreatePublish(ctx, in.GetUsername(), in.GetStreamId())
+	if err != nil {
+		pkg.LogErrorContext(ctx, err)
+		return nil, mediaError(err, "failed to add publish session")
+	}
+
+	// Urls reachable from the caller network
+	callerZone := zone.FromContext(ctx)
+	node := media.NodeName(publish.Node)
+	urls := session.PublishURLs(publish)
+	for protocol, url := range urls {
+		urls[protocol] = zone.Rewrite(callerZone, node, protocol, url)
+	}
+
+	pkg.LogInfoContext(ctx, fmt.Sprintf("publishing on %s", publish.Uuid))
+
+	return &pb.StartPublishResponse{
+		PublishId:  publish.Uuid,
+		Urls:       urls,
+		Credential: publish.Credential,
+	}, nil
+}
+
+func (*Server) StopPublish(ctx context.Context, in *pb.StopPublishRequest) (*emptypb.Empty, error) {
+	// Check value pb.StopPublishRequest
+	if in == nil || in.GetPublishId() == "" {
+		pkg.LogErrorContext(ctx, "invalid message request")
+		return nil, status.Errorf(codes.InvalidArgument, "invalid message request")
+	}
+
+	// Get the peer information from the context
+	client, _ := peer.FromContext(ctx)
+	pkg.LogInfoContext(ctx, fmt.Sprintf("%s requested to stop publish %s from %s", in.GetUsername(), in.GetPublishId(), client.Addr))
+
+	repo := repository.NewPublish(ctx)
+	defer repo.Close()
+
+	// Find publish session of the user
+	publish := repo.FindByUuid(in.GetPublishId())
+	if publish == nil || publish.Owner != in.GetUsername() {
+		pkg.LogErrorContext(ctx, "publish session with specified id not found")
+		return nil, status.Errorf(codes.NotFound, "publish session with specified id not found")
+	}
+
+	// Delete path and publish session
+	if err := session.RemovePublish(ctx, publish); err != nil {
+		pkg.LogErrorContext(ctx, err)
+		return nil, mediaError(err, "failed to remove publish session")
+	}
+
+	return &emptypb.Empty{}, nil
+}
@@ -0,0 +1,231 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"stream-session-api/domain"
+	"stream-session-api/internal/repository"
+	pb "stream-session-api/internal/service/stream/proto"
+	"stream-session-api/internal/session"
+	"stream-session-api/pkg"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (*Server) StartRecording(ctx context.Context, in *pb.StartRecordingRequest) (*pb.Recording, error) {
+	// Check value pb.StartRecordingRequest
+	if in == nil || in.GetUsername() == "" {
+		pkg.LogErrorContext(ctx, "invalid message request")
+		return nil, status.Errorf(codes.InvalidArgument, "invalid message request")
+	}
+	switch in.GetFormat() {
+	case "", domain.RecordFormatFmp4, domain.RecordFormatMpegTs:
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown recording format %q", in.GetFormat())
+	}
+
+	// Get the peer information from the context
+	client, _ := peer.FromContext(ctx)
+	pkg.LogInfoContext(ctx, fmt.Sprintf("%s requested to start recording on %s from %s", in.GetUsername(), in.GetStreamUrl(), client.Addr))
+
+	// Get uuid
+	uuid, err := streamUuid(in.GetStreamUrl())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid stream url request")
+	}
+
+	repo := repository.NewStream(ctx)
+	defer repo.Close()
+
+	// Find stream session of the user
+	stream := repo.FindByUuid(uuid)
+	if stream == nil || stream.Owner != in.GetUsername() {
+		pkg.LogErrorContext(ctx, "stream with specified id not found")
+		return nil, status.Errorf(codes.NotFound, "stream with specified id not found")
+	}
+
+	segment := time.Second * time.Duration(in.GetSegmentDuration())
+	recording, err := session.StartRecording(ctx, in.GetUsername(), stream, in.GetOrigin(), in.GetFormat(), segment, in.GetTags())
+	if err != nil {
+		pkg.LogErrorContext(ctx, err)
+		return nil, mediaError(err, "failed to start recording")
+	}
+
+	pkg.LogInfoContext(ctx, fmt.Sprintf("recording %s of %s on media node %s", recording.Id, recording.Path, recording.Node))
+
+	return recordingMessage(recording), nil
+}
+
+func (*Server) StopRecording(ctx context.Context, in *pb.StopRecordingRequest) (*pb.Recording, error) {
+	// Check value pb.StopRecordingRequest
+	if in == nil || in.GetRecordingId() == "" {
+		pkg.LogErrorContext(ctx, "invalid message request")
+		return nil, status.Errorf(codes.InvalidArgument, "invalid message request")
+	}
+
+	// Get the peer information from the context
+	client, _ := peer.FromContext(ctx)
+	pkg.LogInfoContext(ctx, fmt.Sprintf("%s requested to stop recording %s from %s", in.GetUsername(), in.GetRecordingId(), client.Addr))
+
+	// Find recording of the user
+	recording, err := findRecording(ctx, in.GetUsername(), in.GetRecordingId())
+	if err != nil {
+		return nil, err
+	}
+
+	// Alarm recordings stop after their post-roll
+	if recording.Owner != in.GetUsername() {
+		pkg.LogErrorContext(ctx, "recording stops on its own")
+		return nil, status.Errorf(codes.FailedPrecondition, "recording stops on its own")
+	}
+
+	// Stopping twice returns the ended recording
+	if recording.Active() {
+		if err := session.StopRecording(ctx, recording); err != nil {
+			pkg.LogErrorContext(ctx, err)
+			return nil, mediaError(err, "failed to stop recording")
+		}
+	}
+
+	return recordingMessage(recording), nil
+}
+
+func (*Server) ListRecordings(ctx context.Context, in *pb.ListRecordingsRequest) (*pb.ListRecordingsResponse, error) {
+	// Check value pb.ListRecordingsRequest
+	if in == nil || in.GetUsername() == "" || in.GetStreamId() == "" {
+		pkg.LogErrorContext(ctx, "invalid message request")
+		return nil, status.Errorf(codes.InvalidArgument, "invalid message request")
+	}
+
+	// Get the peer information from the context
+	client, _ := peer.FromContext(ctx)
+	pkg.LogInfoContext(ctx, fmt.Sprintf("%s requested to list recordings of %s from %s", in.GetUsername(), in.GetStreamId(), client.Addr))
+
+	// Range, default to everything until now
+	var start time.Time
+	if in.GetStart() != nil {
+		start = in.GetStart().AsTime()
+	}
+	end := time.Now()
+	if in.GetEnd() != nil {
+		end = in.GetEnd().AsTime()
+	}
+
+	spans, err := session.ListRecordings(ctx, in.GetUsername(), in.GetStreamId(), start, end, in.GetTags())
+	if err != nil {
+		pkg.LogErrorContext(ctx, err)
+		return nil, mediaError(err, "failed to list recordings")
+	}
+
+	resp := &pb.ListRecordingsResponse{}
+	for _, span := range spans {
+		resp.Spans = append(resp.Spans, &pb.RecordingSpan{
+			RecordingId: span.Recording,
+			Start:       timestamppb.New(span.Start),
+			Duration:    span.Duration.Seconds(),
+			Tags:        span.Tags,
+		})
+	}
+
+	return resp, nil
+}
+
+func (*Server) GetPlaybackUrl(ctx context.Context, in *pb.GetPlaybackUrlRequest) (*pb.GetPlaybackUrlResponse, error) {
+	// Check value pb.GetPlaybackUrlRequest
+	if in == nil || in.GetRecordingId() == "" || in.GetStart() == nil || in.GetDuration() <= 0 {
+		pkg.LogErrorContext(ctx, "invalid message request")
+		return nil, status.Errorf(codes.InvalidArgument, "invalid message request")
+	}
+
+	// Get the peer information from the context
+	client, _ := peer.FromContext(ctx)
+	pkg.LogInfoContext(ctx, fmt.Sprintf("%s requested playback of recording %s from %s", in.GetUsername(), in.GetRecordingId(), client.Addr))
+
+	recording, err := findRecording(ctx, in.GetUsername(), in.GetRecordingId())
+	if err != nil {
+		return nil, err
+	}
+
+	duration := time.Duration(in.GetDuration() * float64(time.Second))
+	url, expires, err := session.SignRecordingURL(recording, in.GetStart().AsTime(), duration)
+	if err != nil {
+		pkg.LogErrorContext(ctx, err)
+		return nil, status.Errorf(codes.OutOfRange, "window out of the recording")
+	}
+
+	return &pb.GetPlaybackUrlResponse{
+		Url:       url,
+		ExpiresAt: timestamppb.New(expires),
+	}, nil
+}
+
+func (*Server) ExportClip(ctx context.Context, in *pb.ExportClipRequest) (*pb.ExportClipResponse, error) {
+	// Check value pb.ExportClipRequest
+	if in == nil || in.GetRecordingId() == "" || in.GetStart() == nil || in.GetDuration() <= 0 {
+		pkg.LogErrorContext(ctx, "invalid message request")
+		return nil, status.Errorf(codes.InvalidArgument, "invalid message request")
+	}
+
+	// Get the peer information from the context
+	client, _ := peer.FromContext(ctx)
+	pkg.LogInfoContext(ctx, fmt.Sprintf("%s requested export of recording %s from %s", in.GetUsername(), in.GetRecordingId(), client.Addr))
+
+	recording, err := findRecording(ctx, in.GetUsername(), in.GetRecordingId())
+	if err != nil {
+		return nil, err
+	}
+
+	duration := time.Duration(in.GetDuration() * float64(time.Second))
+	export, err := session.ExportClip(ctx, in.GetUsername(), recording, in.GetStart().AsTime(), duration)
+	if err != nil {
+		pkg.LogErrorContext(ctx, err)
+		return nil, mediaError(err, "failed to export clip")
+	}
+
+	pkg.LogInfoContext(ctx, fmt.Sprintf("recording %s exported to %s", recording.Id, export.File))
+
+	return &pb.ExportClipResponse{
+		ExportId:    export.Id,
+		Token:       export.Token,
+		Url:         session.ExportURL(export),
+		ExpiresAt:   timestamppb.New(export.ExpiresAt),
+		ManifestUrl: session.ExportManifestURL(export),
+	}, nil
+}
+
+// findRecording returns the recording of id visible to username
+func findRecording(ctx context.Context, username, id string) (*domain.Recording, error) {
+	repo := repository.NewRecording(ctx)
+	defer repo.Close()
+
+	recording := repo.FindById(id)
+	if recording == nil || !recording.VisibleTo(username) {
+		pkg.LogErrorContext(ctx, "recording with specified id not found")
+		return nil, status.Errorf(codes.NotFound, "recording with specified id not found")
+	}
+
+	return recording, nil
+}
+
+// recordingMessage maps a recording to its message
+func recordingMessage(recording *domain.Recording) *pb.Recording {
+	msg := &pb.Recording{
+		Id:              recording.Id,
+		StreamId:        recording.StreamId,
+		Path:            recording.Path,
+		Format:          recording.Format,
+		SegmentDuration: uint32(recording.SegmentDuration),
+		StartedAt:       timestamppb.New(recording.StartedAt),
+		Files:           recording.Files,
+		Tags:            recording.Tags,
+		LegalHold:       recording.Hold != nil,
+	}
+	if !recording.Active() {
+		msg.EndedAt = timestamppb.New(recording.EndedAt)
+	}
+	return msg
+}
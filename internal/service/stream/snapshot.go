@@ -0,0 +1,52 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	pb "stream-session-api/internal/service/stream/proto"
+	"stream-session-api/internal/session"
+	"stream-session-api/pkg"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// maxSnapshotSide bounds the requested snapshot size
+const maxSnapshotSide = 3840
+
+func (*Server) GetSnapshot(ctx context.Context, in *pb.GetSnapshotRequest) (*pb.GetSnapshotResponse, error) {
+	// Check value pb.GetSnapshotRequest
+	if in == nil || in.GetUsername() == "" || in.GetStreamId() == "" || in.GetWidth() > maxSnapshotSide || in.GetHeight() > maxSnapshotSide {
+		pkg.LogErrorContext(ctx, "invalid message request")
+		return nil, status.Errorf(codes.InvalidArgument, "invalid message request")
+	}
+
+	// Get the peer information from the context
+	client, _ := peer.FromContext(ctx)
+	pkg.LogInfoContext(ctx, fmt.Sprintf("%s requested snapshot of %s from %s", in.GetUsername(), in.GetStreamId(), client.Addr))
+
+	width, height := uint(in.GetWidth()), uint(in.GetHeight())
+	snapshot, err := session.Snapshot(ctx, in.GetStreamId(), width, height)
+	if err != nil {
+		pkg.LogErrorContext(ctx, err)
+		return nil, mediaError(err, "failed to grab snapshot")
+	}
+
+	resp := &pb.GetSnapshotResponse{
+		Image:   snapshot.Image,
+		TakenAt: timestamppb.New(snapshot.TakenAt),
+	}
+
+	// The image is still served without url
+	url, expires, err := session.SignSnapshotURL(in.GetStreamId(), width, height)
+	if err != nil {
+		pkg.LogWarnContext(ctx, err)
+		return resp, nil
+	}
+	resp.Url = url
+	resp.ExpiresAt = timestamppb.New(expires)
+
+	return resp, nil
+}
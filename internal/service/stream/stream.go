@@ -2,166 +2,387 @@ package stream
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strconv"
 	"stream-session-api/domain"
 	"stream-session-api/internal/conf/network"
+	"stream-session-api/internal/media"
 	"stream-session-api/internal/repository"
+	"stream-session-api/internal/service/hls"
 	pb "stream-session-api/internal/service/stream/proto"
+	"stream-session-api/internal/service/whep"
+	"stream-session-api/internal/session"
+	"stream-session-api/internal/zone"
 	"stream-session-api/pkg"
 	"strings"
+	"time"
 
-	"github.com/go-resty/resty/v2"
-
-	"github.com/google/uuid"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type Server struct {
 	pb.StreamServiceServer
 }
 
+// reservationTtl bounds the reservation of an idempotency key in progress,
+// beside the wait for the source, in case its request never ends
+const reservationTtl = time.Minute
+
 func (*Server) StartStream(ctx context.Context, in *pb.StartStreamRequest) (*pb.StartStreamResponse, error) {
 	// Check value pb.StartStreamRequest
 	if in == nil {
-		pkg.LogError("invalid message request")
+		pkg.LogErrorContext(ctx, "invalid message request")
 		return nil, status.Errorf(codes.InvalidArgument, "ionvalid message request")
 	}
 
 	// Get the peer information from the context
 	client, _ := peer.FromContext(ctx)
-	pkg.LogInfo(fmt.Sprintf("%s requested to start stream for %s from %s", in.GetUsername(), in.GetStreamId(), client.Addr))
-
-	// Stream request for specific id
-	stream := &domain.Stream{
-		Id:   in.GetStreamId(),
-		Uuid: uuid.New().String(),
-	}
-
-	//* you must make a mapping between stream.id and rtsp.subpath ex => id: 001 to subpath: /74630a72-2612-478b-9c49-308c720aa619
-	//? Why ? Because, you should not stream a video from a server directly if stream.id==rtsp.subPath make it stream.id!=rtsp.subPath
-	//! Temporary, i make it to be the same
-	subPath := "/" + stream.Id
-
-	// Set body for http post
-	body, _ := json.Marshal(
-		struct {
-			Name   string `json:"name"`
-			Source string `json:"source"`
-		}{
-			Name: stream.Uuid,
-			Source: fmt.Sprintf("rtsp://%s:%d%s%s",
-				network.Get().MediaMtx.Rtsp.Ip,
-				network.Get().MediaMtx.Rtsp.Port,
-				network.Get().MediaMtx.Rtsp.Path,
-				subPath,
-			),
-		},
-	)
-
-	// Add stream session via http request
-	httpClient := resty.New()
-	resp, err := httpClient.R().
-		SetContext(ctx).
-		SetHeader("Content-Type", "application/json").
-		SetBody(string(body)).
-		Post(fmt.Sprintf("http://%s:%d/v3/config/paths/add/%s",
-			network.Get().MediaMtx.Http.Ip,
-			network.Get().MediaMtx.Http.Port,
-			stream.Uuid))
-	if err != nil {
-		pkg.LogError(err)
-		return nil, status.Errorf(codes.Unavailable, "failed to configure stream")
+	pkg.LogInfoContext(ctx, fmt.Sprintf("%s requested to start stream for %s from %s", in.GetUsername(), in.GetStreamId(), client.Addr))
+
+	// Check requested protocols
+	for _, protocol := range in.GetProtocols() {
+		if !slices.Contains(domain.Protocols, protocol) {
+			return nil, status.Errorf(codes.InvalidArgument, "unknown protocol %q", protocol)
+		}
 	}
 
-	switch resp.StatusCode() {
-	case 400:
-		pkg.LogError(err)
-		return nil, status.Errorf(codes.FailedPrecondition, "%d failed to add stream session", resp.StatusCode())
-	case 500:
-		pkg.LogError(err)
-		return nil, status.Errorf(codes.Unimplemented, "%d failed to add stream session", resp.StatusCode())
+	// Check requested profile
+	if profile := streamProfile(in); profile != "" {
+		if _, ok := network.Get().Transcoding.Profile(profile); !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "unknown profile %q", profile)
+		}
+	}
 
+	// Idempotency key from the request or the metadata
+	key := in.GetIdempotencyKey()
+	if md, ok := metadata.FromIncomingContext(ctx); ok && key == "" {
+		if values := md.Get("idempotency-key"); len(values) > 0 {
+			key = values[0]
+		}
 	}
+	if key == "" {
+		return startStream(ctx, in)
+	}
+
+	// Result window, default to 10 minutes
+	window, _ := strconv.ParseInt(os.Getenv("IDEMPOTENCY_WINDOW"), 10, 32)
+	if window <= 0 {
+		window = 600
+	}
+	ttl := time.Second * time.Duration(window)
 
-	// Insert stream url to redis
-	repo := repository.NewStream()
+	// The key outlives a canceled request, a retry must find its result
+	repo := repository.NewIdempotency(context.WithoutCancel(ctx))
 	defer repo.Close()
 
-	if err := repo.Insert(stream); err != nil {
-		pkg.LogError(err)
-		return nil, status.Errorf(codes.Unknown, "cannot do streaming")
+	// Reserve the key until the request ends, a taken key returns its result
+	result := &domain.Idempotency{
+		Key:       key,
+		Owner:     in.GetUsername(),
+		StreamId:  in.GetStreamId(),
+		Protocols: in.GetProtocols(),
+		Profile:   streamProfile(in),
+	}
+	reserved, err := repo.Reserve(result, reservationTtl+waitReadyTimeout(in))
+	if err != nil {
+		pkg.LogErrorContext(ctx, err)
+		return nil, status.Errorf(codes.Unknown, "failed to check idempotency key")
+	}
+	if !reserved {
+		prev := repo.FindByKey(in.GetUsername(), key)
+		switch {
+		case prev != nil && prev.StreamId != in.GetStreamId():
+			return nil, status.Errorf(codes.InvalidArgument, "idempotency key already used for another stream")
+		case prev != nil && (prev.Profile != result.Profile || !sameProtocols(prev.Protocols, result.Protocols)):
+			return nil, status.Errorf(codes.InvalidArgument, "idempotency key already used with other protocols or profile")
+		case prev == nil || prev.Url == "":
+			return nil, status.Errorf(codes.Aborted, "request with the same idempotency key in progress")
+		default:
+			pkg.LogInfoContext(ctx, fmt.Sprintf("idempotency key %s already streaming on %s", key, prev.Url))
+			return &pb.StartStreamResponse{
+				StreamUrl:  prev.Url,
+				Tracks:     prev.Tracks,
+				Urls:       prev.Urls,
+				Token:      prev.Token,
+				IceServers: iceServers(prev.IceServers),
+			}, nil
+		}
+	}
+
+	resp, err := startStream(ctx, in)
+	if err != nil {
+		// Let the client retry with the same key
+		if err := repo.Delete(in.GetUsername(), key); err != nil {
+			pkg.LogWarnContext(ctx, fmt.Sprintf("failed to release idempotency key %s: %v", key, err))
+		}
+		return nil, err
+	}
+
+	result.Url = resp.GetStreamUrl()
+	result.Tracks = resp.GetTracks()
+	result.Urls = resp.GetUrls()
+	result.Token = resp.GetToken()
+	for _, server := range resp.GetIceServers() {
+		result.IceServers = append(result.IceServers, domain.IceServer{
+			Urls:       server.GetUrls(),
+			Username:   server.GetUsername(),
+			Credential: server.GetCredential(),
+		})
+	}
+	if err := repo.Save(result, ttl); err != nil {
+		pkg.LogWarnContext(ctx, fmt.Sprintf("failed to save idempotency key %s: %v", key, err))
+	}
+
+	return resp, nil
+}
+
+// startStream creates the stream session of a request, or reuses a live one
+func startStream(ctx context.Context, in *pb.StartStreamRequest) (*pb.StartStreamResponse, error) {
+	// Reuse a live stream session
+	var stream *domain.Stream
+	if in.GetReuse() {
+		var err error
+		stream, err = session.Find(ctx, in.GetUsername(), in.GetStreamId(), streamProfile(in))
+		if err != nil {
+			pkg.LogErrorContext(ctx, err)
+			return nil, mediaError(err, "failed to find stream session")
+		}
+	}
+	reused := stream != nil
+
+	// Create stream session
+	if !reused {
+		var err error
+		stream, err = session.Create(ctx, in.GetUsername(), in.GetStreamId(), streamProfile(in), nil)
+		if err != nil {
+			pkg.LogErrorContext(ctx, err)
+			return nil, mediaError(err, "failed to add stream session")
+		}
 	}
 
 	// Set stream url
-	url := fmt.Sprintf("http://%s:%d/%s",
-		network.Get().MediaMtx.WebRtc.Ip,
-		network.Get().MediaMtx.WebRtc.Port,
-		stream.Uuid)
-	pkg.LogInfo(fmt.Sprintf("streaming on %s", url))
+	resp := &pb.StartStreamResponse{
+		StreamUrl:  session.PlaybackURL(stream),
+		Urls:       session.PlaybackURLs(stream, in.GetProtocols()),
+		IceServers: iceServers(session.IceServers(stream)),
+	}
+
+	// Urls reachable from the caller network
+	callerZone := zone.FromContext(ctx)
+	node := media.NodeName(stream.Node)
+	resp.StreamUrl = zone.Rewrite(callerZone, node, domain.ProtocolWebRtc, resp.StreamUrl)
+	for protocol, url := range resp.Urls {
+		resp.Urls[protocol] = zone.Rewrite(callerZone, node, protocol, url)
+	}
 
-	return &pb.StartStreamResponse{StreamUrl: url}, nil
+	// Signaling and hls through the proxies, clients never reach the media node
+	resp.Token = stream.Token
+	if _, ok := resp.Urls[domain.ProtocolWhep]; ok && network.Get().Http.Whep {
+		resp.Urls[domain.ProtocolWhep] = whep.URL(stream)
+	}
+	if _, ok := resp.Urls[domain.ProtocolHls]; ok && network.Get().Http.Hls {
+		resp.Urls[domain.ProtocolHls] = hls.URL(stream)
+	}
+
+	// Wait for the source before handing out the url
+	if in.GetWaitReady() {
+		path, err := session.WaitReady(ctx, stream, waitReadyTimeout(in))
+		if err != nil {
+			pkg.LogErrorContext(ctx, err)
+
+			// Clean up a session nobody can watch
+			if !reused {
+				if err := session.Remove(context.WithoutCancel(ctx), stream); err != nil {
+					pkg.LogWarnContext(ctx, fmt.Sprintf("failed to remove %v: %v", *stream, err))
+				}
+			}
+			var reason pkg.Error
+			if errors.As(err, &reason) && reason.SvcError() == pkg.ErrUnavailable {
+				return nil, status.Errorf(codes.Unavailable, "stream source not ready: %v", reason.AppError())
+			}
+			return nil, mediaError(err, "failed to get stream session status")
+		}
+		resp.Tracks = path.Tracks
+	}
+
+	if reused {
+		pkg.LogInfoContext(ctx, fmt.Sprintf("reuse streaming on %s", resp.GetStreamUrl()))
+	} else {
+		pkg.LogInfoContext(ctx, fmt.Sprintf("streaming on %s", resp.GetStreamUrl()))
+	}
+
+	return resp, nil
+}
+
+// waitReadyTimeout returns how long a request waits for its source, 0 if it does not
+func waitReadyTimeout(in *pb.StartStreamRequest) time.Duration {
+	if !in.GetWaitReady() {
+		return 0
+	}
+
+	timeout := time.Second * time.Duration(in.GetWaitReadyTimeout())
+	if timeout <= 0 {
+		val, _ := strconv.ParseInt(os.Getenv("WAIT_READY_TIMEOUT"), 10, 32)
+		timeout = time.Second * time.Duration(max(val, 1))
+	}
+	return timeout
+}
+
+// sameProtocols reports whether two protocol lists ask for the same protocols
+func sameProtocols(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(slices.Compact(a), slices.Compact(b))
+}
+
+// streamProfile returns the transcoding profile of a request, empty for the source
+func streamProfile(in *pb.StartStreamRequest) string {
+	if in.GetProfile() == network.ProfileSource {
+		return ""
+	}
+	return in.GetProfile()
 }
 
 func (*Server) StopStream(ctx context.Context, in *pb.StopStreamRequest) (*emptypb.Empty, error) {
 	// Check value pb.StartStreamRequest
 	if in == nil {
-		pkg.LogError("invalid message request")
+		pkg.LogErrorContext(ctx, "invalid message request")
 		return nil, status.Errorf(codes.InvalidArgument, "invalid message request")
 	}
 
 	// Get the peer information from the context
 	client, _ := peer.FromContext(ctx)
-	pkg.LogInfo(fmt.Sprintf("%s requested to stop stream on %s from %s", in.GetUsername(), in.GetStreamUrl(), client.Addr))
+	pkg.LogInfoContext(ctx, fmt.Sprintf("%s requested to stop stream on %s from %s", in.GetUsername(), in.GetStreamUrl(), client.Addr))
 
 	// Get uuid
-	parsedUrl, err := url.ParseRequestURI(in.GetStreamUrl())
+	uuid, err := streamUuid(in.GetStreamUrl())
 	if err != nil {
 		return nil, status.Errorf(codes.InvalidArgument, "invalid stream url request")
 	}
-	uuid := strings.TrimSuffix(parsedUrl.Path, "/")
-	uuid = strings.TrimPrefix(uuid, "/")
 
-	repo := repository.NewStream()
+	repo := repository.NewStream(ctx)
 	defer repo.Close()
 
 	// Find stream by uuid
 	stream := repo.FindByUuid(uuid)
 	if stream == nil {
-		pkg.LogError("stream with specified id not found")
+		pkg.LogErrorContext(ctx, "stream with specified id not found")
 		return nil, status.Errorf(codes.NotFound, "stream with specified id not found")
 	}
 
-	// Stop stream
-	httpClient := resty.New()
-	resp, err := httpClient.R().
-		SetContext(ctx).
-		Delete(fmt.Sprintf("http://%s:%d/v3/config/paths/delete/%s",
-			network.Get().MediaMtx.Http.Ip,
-			network.Get().MediaMtx.Http.Port,
-			uuid))
+	// Stop stream and delete uuid on redis
+	if err := session.Remove(ctx, stream); err != nil {
+		pkg.LogErrorContext(ctx, err)
+		return nil, mediaError(err, "failed to remove stream session")
+	}
+
+	return &emptypb.Empty{}, nil
+}
 
+// streamUuid returns the uuid of the stream session of a stream url
+func streamUuid(streamUrl string) (string, error) {
+	parsedUrl, err := url.ParseRequestURI(streamUrl)
 	if err != nil {
-		pkg.LogError(err)
-		return nil, status.Errorf(codes.Unavailable, "failed to stop stream")
+		return "", err
+	}
+
+	// go2rtc urls carry the stream in the src query
+	if uuid := parsedUrl.Query().Get("src"); uuid != "" {
+		return uuid, nil
+	}
+
+	// Zone urls may prefix the path
+	return path.Base(strings.TrimSuffix(parsedUrl.Path, "/")), nil
+}
+
+// iceServers maps ice servers to their message
+func iceServers(servers []domain.IceServer) []*pb.IceServer {
+	var result []*pb.IceServer
+	for _, server := range servers {
+		result = append(result, &pb.IceServer{
+			Urls:       server.Urls,
+			Username:   server.Username,
+			Credential: server.Credential,
+		})
 	}
-	switch resp.StatusCode() {
-	case 400:
-		pkg.LogError(err)
-		return nil, status.Errorf(codes.FailedPrecondition, "%d failed to remove stream session", resp.StatusCode())
-	case 500:
-		pkg.LogError(err)
-		return nil, status.Errorf(codes.Unimplemented, "%d failed to remove stream session", resp.StatusCode())
+	return result
+}
 
+// mediaError maps a stream session error to a grpc status
+func mediaError(err error, msg string) error {
+	switch {
+	case errors.Is(err, pkg.ErrUnavailable):
+		return status.Errorf(codes.Unavailable, "%s", msg)
+	case errors.Is(err, pkg.ErrBadRequest):
+		return status.Errorf(codes.FailedPrecondition, "%s", msg)
+	case errors.Is(err, pkg.ErrNotFound):
+		return status.Errorf(codes.NotFound, "%s", msg)
+	case errors.Is(err, pkg.ErrResourceNotFound):
+		return status.Errorf(codes.ResourceExhausted, "%s", msg)
+	case errors.Is(err, pkg.ErrProcessFail):
+		return status.Errorf(codes.Unknown, "%s", msg)
+	case errors.Is(err, pkg.ErrWriteFile):
+		return status.Errorf(codes.Internal, "%s", msg)
+	default:
+		return status.Errorf(codes.Unimplemented, "%s", msg)
 	}
-	// Delete uuid on redis
-	if err := repo.Delete(uuid); err != nil {
-		return nil, status.Errorf(codes.Unknown, "failed to close stream")
+}
+
+func (*Server) WatchEvents(in *pb.WatchEventsRequest, srv pb.StreamService_WatchEventsServer) error {
+	ctx := srv.Context()
+
+	// Check value pb.WatchEventsRequest
+	if in == nil || in.GetUsername() == "" {
+		pkg.LogErrorContext(ctx, "invalid message request")
+		return status.Errorf(codes.InvalidArgument, "invalid message request")
 	}
 
-	return &emptypb.Empty{}, nil
+	// Get the peer information from the context
+	client, _ := peer.FromContext(ctx)
+	pkg.LogInfoContext(ctx, fmt.Sprintf("%s requested to watch events from %s", in.GetUsername(), client.Addr))
+
+	repo := repository.NewEvent(ctx)
+	defer repo.Close()
+
+	// Urls reachable from the watcher network
+	callerZone := zone.FromContext(ctx)
+
+	events, err := repo.Subscribe(in.GetUsername())
+	if err != nil {
+		pkg.LogErrorContext(ctx, err)
+		return status.Errorf(codes.Unavailable, "failed to watch events")
+	}
+
+	// Forward events until the client leaves
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			err := srv.Send(&pb.StreamEvent{
+				Type:     event.Type,
+				StreamId: event.StreamId,
+				OldUrl:   zone.Rewrite(callerZone, event.OldNode, domain.ProtocolWebRtc, event.OldUrl),
+				NewUrl:   zone.Rewrite(callerZone, event.NewNode, domain.ProtocolWebRtc, event.NewUrl),
+				Time:     timestamppb.New(event.Time),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
 }
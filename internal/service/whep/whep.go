@@ -0,0 +1,191 @@
+package whep
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"path"
+	"strconv"
+	"stream-session-api/domain"
+	"stream-session-api/internal/conf/network"
+	"stream-session-api/internal/media"
+	"stream-session-api/internal/session"
+	"stream-session-api/pkg"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// Register adds the whep signaling routes, they mirror the MediaMTX ones
+func Register(mux *http.ServeMux) {
+	mux.HandleFunc("OPTIONS /{uuid}/whep", handle)
+	mux.HandleFunc("POST /{uuid}/whep", handle)
+	mux.HandleFunc("PATCH /{uuid}/whep/{session}", handle)
+	mux.HandleFunc("DELETE /{uuid}/whep/{session}", handle)
+}
+
+// URL returns the whep proxy url of a stream session
+func URL(stream *domain.Stream) string {
+	return fmt.Sprintf("%s/%s/whep?token=%s", network.Get().Http.BaseUrl(), stream.Uuid, stream.Token)
+}
+
+// handle authenticates the session token and forwards the request to the
+// media node hosting the stream session
+func handle(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Browser preflight carries no token
+	if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "OPTIONS, POST, PATCH, DELETE")
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, If-Match")
+		w.Header().Set("Access-Control-Expose-Headers", "Link, Location, ETag, Accept-Patch")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	stream, err := session.Authorize(ctx, r.PathValue("uuid"), pkg.RequestToken(r))
+	if err != nil {
+		pkg.LogWarnContext(ctx, fmt.Sprintf("whep %s %s refused: %v", r.Method, r.URL.Path, err))
+		if errors.Is(err, pkg.ErrNotFound) {
+			http.Error(w, "stream session not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+		}
+		return
+	}
+
+	// Only MediaMTX nodes serve whep
+	if backend := network.Get().Media; backend != "" && backend != media.BackendMediaMtx {
+		http.Error(w, "whep not supported by the media backend", http.StatusNotImplemented)
+		return
+	}
+	node := network.Get().Node(stream.Node)
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL.Scheme = "http"
+			pr.Out.URL.Host = net.JoinHostPort(node.WebRtc.Ip, strconv.FormatUint(uint64(node.WebRtc.Port), 10))
+			pr.Out.URL.RawQuery = ""
+			pr.Out.Host = ""
+			pr.Out.Header.Del("Authorization")
+			pr.SetXForwarded()
+		},
+		Transport: otelhttp.NewTransport(http.DefaultTransport),
+		ModifyResponse: func(resp *http.Response) error {
+			if err := rewriteBody(resp, node.IceHosts); err != nil {
+				return err
+			}
+			track(r, resp, stream)
+			return rewriteLocation(resp, stream)
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			pkg.LogErrorContext(r.Context(), fmt.Sprintf("whep %s %s to media node %s failed: %v", r.Method, r.URL.Path, node.Name, err))
+			http.Error(w, "media node unavailable", http.StatusBadGateway)
+		},
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+// rewriteBody rewrites the ice candidates of an sdp answer or fragment
+func rewriteBody(resp *http.Response, hosts []string) error {
+	contentType := resp.Header.Get("Content-Type")
+	if len(hosts) == 0 || !strings.HasPrefix(contentType, "application/sdp") &&
+		!strings.HasPrefix(contentType, "application/trickle-ice-sdpfrag") {
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	body = []byte(rewriteCandidates(string(body), hosts))
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+
+	return nil
+}
+
+// rewriteLocation adds the session token to the whep session url, PATCH and
+// DELETE of the player go through the proxy with it
+func rewriteLocation(resp *http.Response, stream *domain.Stream) error {
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return nil
+	}
+
+	u, err := url.Parse(location)
+	if err != nil {
+		return err
+	}
+	query := u.Query()
+	query.Set("token", stream.Token)
+	u.RawQuery = query.Encode()
+	resp.Header.Set("Location", u.String())
+
+	return nil
+}
+
+// rewriteCandidates replaces the address of the host candidates with the
+// public addresses of the node
+func rewriteCandidates(sdp string, hosts []string) string {
+	lines := strings.Split(sdp, "\r\n")
+	out := make([]string, 0, len(lines))
+	seen := make(map[string]bool)
+	for _, line := range lines {
+		// a=candidate:<foundation> <component> <transport> <priority> <address> <port> typ <type> ...
+		fields := strings.Fields(line)
+		if !strings.HasPrefix(line, "a=candidate:") || len(fields) < 8 || fields[7] != "host" {
+			out = append(out, line)
+			continue
+		}
+
+		for _, host := range hosts {
+			fields[4] = host
+			key := strings.Join([]string{fields[1], fields[2], fields[4], fields[5]}, " ")
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			out = append(out, strings.Join(fields, " "))
+		}
+	}
+
+	return strings.Join(out, "\r\n")
+}
+
+// track records the whep session of a viewer
+func track(r *http.Request, resp *http.Response, stream *domain.Stream) {
+	ctx := r.Context()
+
+	viewer := &domain.Viewer{
+		Stream:   stream.Uuid,
+		Owner:    stream.Owner,
+		Node:     stream.Node,
+		Protocol: domain.ProtocolWhep,
+		Address:  pkg.RequestAddress(r, network.Get().Http.Trusted),
+	}
+
+	var err error
+	switch {
+	case r.Method == http.MethodPost && resp.StatusCode == http.StatusCreated:
+		viewer.Id = path.Base(resp.Header.Get("Location"))
+		err = session.TouchViewer(ctx, viewer)
+	case r.Method == http.MethodPatch && resp.StatusCode < 300:
+		viewer.Id = r.PathValue("session")
+		err = session.TouchViewer(ctx, viewer)
+	case r.Method == http.MethodDelete && resp.StatusCode < 300:
+		err = session.RemoveViewer(ctx, stream, r.PathValue("session"))
+	}
+	if err != nil {
+		pkg.LogWarnContext(ctx, fmt.Sprintf("failed to track whep viewer of %s: %v", stream.Uuid, err))
+	}
+}
@@ -1,14 +1,18 @@
 package worker
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"strconv"
 	"stream-session-api/internal/conf/network"
+	"stream-session-api/internal/service/admin"
+	adminpb "stream-session-api/internal/service/admin/proto"
 	"stream-session-api/internal/service/stream"
 	pb "stream-session-api/internal/service/stream/proto"
 	"stream-session-api/pkg"
 
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
 )
@@ -34,10 +38,17 @@ func InitGrpcServer() error {
 
 	pkg.LogInfo(fmt.Sprintf("gRPC listening on %s...", lis.Addr()))
 
-	opts := []grpc.ServerOption{}
+	opts := []grpc.ServerOption{
+		// Trace every rpc, the parent span is extracted from incoming metadata
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		// Admin rpcs require the admin token
+		grpc.UnaryInterceptor(admin.UnaryInterceptor),
+		grpc.StreamInterceptor(admin.StreamInterceptor),
+	}
 	s = grpc.NewServer(opts...)
 
 	pb.RegisterStreamServiceServer(s, &stream.Server{})
+	adminpb.RegisterAdminServiceServer(s, &admin.Server{})
 	reflection.Register(s)
 
 	return nil
@@ -49,3 +60,20 @@ func GrpcServer() {
 
 	}
 }
+
+// StopGrpcServer waits for in-flight rpcs until ctx is done, then forces the stop
+func StopGrpcServer(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		pkg.LogInfo("gRPC server stopped")
+	case <-ctx.Done():
+		pkg.LogWarn("gRPC graceful stop deadline exceeded, force stop")
+		s.Stop()
+	}
+}
@@ -0,0 +1,77 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"stream-session-api/internal/conf/network"
+	"stream-session-api/internal/service/alarm"
+	"stream-session-api/internal/service/auth"
+	"stream-session-api/internal/service/hls"
+	"stream-session-api/internal/service/recording"
+	"stream-session-api/internal/service/snapshot"
+	"stream-session-api/internal/service/whep"
+	"stream-session-api/pkg"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+var (
+	httpLis net.Listener
+	hs      *http.Server
+)
+
+func InitHttpServer() error {
+	// Get config instance
+	conf := network.Get()
+
+	// Http server address
+	httpAddr := conf.Http.Ip + ":" + strconv.FormatUint(uint64(conf.Http.Port), 10)
+
+	var err error
+	httpLis, err = net.Listen("tcp", httpAddr)
+	if err != nil {
+		pkg.LogFatal(err.Error())
+		return err
+	}
+
+	pkg.LogInfo(fmt.Sprintf("HTTP listening on %s...", httpLis.Addr()))
+
+	mux := http.NewServeMux()
+	whep.Register(mux)
+	hls.Register(mux)
+	auth.Register(mux)
+	recording.Register(mux)
+	alarm.Register(mux)
+	snapshot.Register(mux)
+
+	// Trace every request, the parent span is extracted from incoming headers
+	hs = &http.Server{
+		Handler: otelhttp.NewHandler(mux, "http",
+			otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+				return "HTTP " + r.Method
+			}),
+		),
+	}
+
+	return nil
+}
+
+func HttpServer() {
+	if err := hs.Serve(httpLis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		pkg.LogFatal(fmt.Sprintf("Failed to serve: %v", err))
+	}
+}
+
+// StopHttpServer waits for in-flight requests until ctx is done, then forces the stop
+func StopHttpServer(ctx context.Context) {
+	if err := hs.Shutdown(ctx); err != nil {
+		pkg.LogWarn("HTTP graceful stop deadline exceeded, force stop")
+		hs.Close()
+		return
+	}
+	pkg.LogInfo("HTTP server stopped")
+}
@@ -1,108 +1,248 @@
 package worker
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
-	"stream-session-api/dto"
-	"stream-session-api/internal/conf/network"
+	"stream-session-api/domain"
+	"stream-session-api/internal/media"
+	"stream-session-api/internal/placement"
 	"stream-session-api/internal/repository"
+	"stream-session-api/internal/session"
 	"stream-session-api/pkg"
 	"time"
-
-	"github.com/go-resty/resty/v2"
 )
 
-func inactiveSessionHandler() error {
-	// Get config instance
-	config := network.Get()
+// inactiveSessionHandler removes the inactive and expired stream sessions, it
+// returns the unreachable media nodes whose state is unknown
+func inactiveSessionHandler(ctx context.Context) (map[string]bool, error) {
+	// Get readers of every node
+	active := make(map[string]bool)
+	failed := make(map[string]bool)
+	for _, node := range pooledNodes(ctx) {
+		backend := media.Node(node)
+
+		// Recover paths lost by a media server restart before looking for sessions
+		if err := recoverIfRestarted(ctx, node); err != nil {
+			pkg.LogWarnContext(ctx, fmt.Sprintf("skip media node %s: %v", node, err))
+			failed[node] = true
+			continue
+		}
+
+		readers, err := backend.ListReaders(ctx)
+		if err != nil {
+			pkg.LogWarnContext(ctx, fmt.Sprintf("skip media node %s: %v", node, err))
+			failed[node] = true
+			continue
+		}
+		for _, reader := range readers {
+			active[reader.Path] = true
+		}
+		// Bandwidth of the node for the placement
+		placement.Sample(node, readers)
+	}
 
-	// Get WebRTC Session
-	client := resty.New()
-	resp, err := client.R().
-		SetHeader("Accept", "application/json").
-		SetResult(&dto.StreamSessionList{}).
-		Get(fmt.Sprintf("http://%s:%d/v3/webrtcsessions/list",
-			config.MediaMtx.Http.Ip,
-			config.MediaMtx.Http.Port))
+	// Viewers seen by the proxies
+	viewerRepo := repository.NewViewer(ctx)
+	defer viewerRepo.Close()
 
+	viewers, err := viewerRepo.GetAll()
 	if err != nil {
-		return err
+		return failed, pkg.NewError(pkg.ErrProcessFail, err)
 	}
-	if resp.StatusCode() != 200 {
-		return err
+	for _, viewer := range viewers {
+		active[viewer.Stream] = true
 	}
-	sessions := resp.Result().(*dto.StreamSessionList)
 
 	// Get all stream
-	repo := repository.NewStream()
+	repo := repository.NewStream(ctx)
 	defer repo.Close()
 
 	streams, err := repo.GetAll()
-	if err != nil || streams == nil {
-		return pkg.NewError(pkg.ErrProcessFail, fmt.Errorf("stream list not found"))
+	if err != nil {
+		return failed, pkg.NewError(pkg.ErrProcessFail, err)
 	}
 
-	// Cleanup inactive session
+	// Cleanup inactive and expired session
+	now := time.Now()
 	for _, stream := range streams {
-		match := false
-		for _, session := range sessions.Items {
-			if stream.Uuid == session.Path {
-				pkg.LogInfo(fmt.Sprintf("%v active", *stream))
-				match = true
-				break
-			}
+		// Unknown state on an unreachable node
+		node := media.NodeName(stream.Node)
+		if failed[node] {
+			continue
 		}
 
-		if !match {
-			pkg.LogInfo(fmt.Sprintf("%v inactive", *stream))
-			// Stop stream path
-			client := resty.New()
-			resp, err := client.R().
-				Delete(fmt.Sprintf("http://%s:%d/v3/config/paths/delete/%s",
-					config.MediaMtx.Http.Ip,
-					config.MediaMtx.Http.Port,
-					stream.Uuid))
+		if active[stream.Uuid] && !stream.Expired(now) {
+			pkg.LogInfoContext(ctx, fmt.Sprintf("%v active", *stream))
+			continue
+		}
 
-			if err != nil {
-				return pkg.NewError(pkg.ErrProcessFail, fmt.Errorf("failed to stop stream"))
-			}
-			if resp.StatusCode() != 200 {
-				return pkg.NewError(pkg.ErrProcessFail, fmt.Errorf("%d failed to delete path stream", resp.StatusCode()))
-			}
+		// Readers dropped by a restart have not reconnected to the recovered path yet
+		if !stream.Expired(now) && recentlyRecovered(node, now) {
+			pkg.LogInfoContext(ctx, fmt.Sprintf("%v recovered, waiting for readers", *stream))
+			continue
+		}
+
+		pkg.LogInfoContext(ctx, fmt.Sprintf("%v inactive or expired", *stream))
+		// Stop stream path and delete stream redis log, retried by the next check
+		if err := session.Remove(ctx, stream); err != nil {
+			pkg.LogWarnContext(ctx, fmt.Sprintf("failed to remove %v: %v", *stream, err))
+		}
+	}
+
+	return failed, nil
+}
+
+// removeExpiredExports deletes the exported clips past their lifetime
+func removeExpiredExports(ctx context.Context) error {
+	repo := repository.NewExport(ctx)
+	defer repo.Close()
+
+	exports, err := repo.GetAll()
+	if err != nil {
+		return pkg.NewError(pkg.ErrProcessFail, err)
+	}
+
+	now := time.Now()
+	for _, export := range exports {
+		if now.Before(export.ExpiresAt) {
+			continue
+		}
+
+		pkg.LogInfoContext(ctx, fmt.Sprintf("export %s of recording %s expired", export.Id, export.Recording))
+		if err := session.RemoveExport(ctx, export); err != nil {
+			pkg.LogWarnContext(ctx, fmt.Sprintf("failed to remove export %s: %v", export.Id, err))
+		}
+	}
+
+	return nil
+}
+
+// reapPublishSessions removes the publish sessions whose publisher never
+// connected in time or has disconnected
+func reapPublishSessions(ctx context.Context, failed map[string]bool) error {
+	repo := repository.NewPublish(ctx)
+	defer repo.Close()
 
-			// Delete stream redis log
-			if err := repo.Delete(stream.Uuid); err != nil {
-				return pkg.NewError(pkg.ErrProcessFail, fmt.Errorf("failed to close stream"))
+	publishes, err := repo.GetAll()
+	if err != nil {
+		return pkg.NewError(pkg.ErrProcessFail, err)
+	}
+
+	// Connect timeout, default to 1 minute
+	val, _ := strconv.ParseInt(os.Getenv("PUBLISH_CONNECT_TIMEOUT"), 10, 32)
+	if val <= 0 {
+		val = 60
+	}
+	timeout := time.Second * time.Duration(val)
+
+	now := time.Now()
+	for _, publish := range publishes {
+		// Unknown state on an unreachable node
+		if failed[media.NodeName(publish.Node)] {
+			continue
+		}
+
+		status, err := media.Node(publish.Node).PathStatus(ctx, publish.Uuid)
+		if err != nil && !errors.Is(err, pkg.ErrNotFound) {
+			pkg.LogWarnContext(ctx, fmt.Sprintf("skip %v: %v", *publish, err))
+			continue
+		}
+
+		switch {
+		case status != nil && status.Ready:
+			// Record the first time the publisher is seen
+			if publish.ConnectedAt.IsZero() {
+				publish.ConnectedAt = now
+				if err := repo.Insert(publish); err != nil {
+					pkg.LogWarnContext(ctx, fmt.Sprintf("failed to save %v: %v", *publish, err))
+				}
 			}
+			pkg.LogInfoContext(ctx, fmt.Sprintf("%v publishing", *publish))
+			continue
+		case status != nil && publish.ConnectedAt.IsZero() && now.Sub(publish.CreatedAt) < timeout:
+			pkg.LogInfoContext(ctx, fmt.Sprintf("%v waiting for publisher", *publish))
+			continue
+		}
+
+		pkg.LogInfoContext(ctx, fmt.Sprintf("%v publisher gone or never connected", *publish))
+		if err := session.RemovePublish(ctx, publish); err != nil {
+			pkg.LogWarnContext(ctx, fmt.Sprintf("failed to remove %v: %v", *publish, err))
 		}
 	}
 
 	return nil
 }
 
-func PeriodicStreamSessionCheck() {
+// PeriodicStreamSessionCheck starts the check loop, it stops when ctx is done.
+// An in-flight check is not cancelled, Shutdown waits for it.
+func PeriodicStreamSessionCheck(ctx context.Context) {
+	wg.Add(1)
 	go func() {
+		defer wg.Done()
+
 		val, _ := strconv.ParseInt(os.Getenv("PERIODIC_STREAM_SESSION_CHECK"), 10, 16)
 		ticker := time.NewTicker(time.Second * time.Duration(val))
 		defer ticker.Stop()
 
 		// Schedule on
-		for range ticker.C {
+		for {
+			select {
+			case <-ctx.Done():
+				pkg.LogInfo("stream session check stopped")
+				return
+			case <-ticker.C:
+			}
+
 			currentTime := time.Now()
 			pkg.LogInfo(fmt.Sprintf("STREAM_SESSION_CHECK: %d/%02d/%02d %d:%d:%d",
 				currentTime.Year(), int(currentTime.Month()), currentTime.Day(),
 				currentTime.Hour(), currentTime.Minute(), currentTime.Second()))
 
 			// Check inactive Stream Session
-			err := inactiveSessionHandler()
+			checkCtx, span := pkg.StartSpan(context.WithoutCancel(ctx), "PeriodicStreamSessionCheck")
+			failed, err := inactiveSessionHandler(checkCtx)
 			if err != nil {
-				pkg.LogWarn(fmt.Sprintf("failed to check stream session: %v", err))
+				span.RecordError(err)
+				pkg.LogWarnContext(checkCtx, fmt.Sprintf("failed to check stream session: %v", err))
+			}
+
+			// Publish sessions, reaped even when the stream sessions could not be checked
+			if err := reapPublishSessions(checkCtx, failed); err != nil {
+				span.RecordError(err)
+				pkg.LogWarnContext(checkCtx, fmt.Sprintf("failed to reap publish sessions: %v", err))
+			}
+
+			// Alarm recordings missed by their timer, e.g. after a restart
+			if err := session.StopDueRecordings(checkCtx); err != nil {
+				span.RecordError(err)
+				pkg.LogWarnContext(checkCtx, fmt.Sprintf("failed to stop alarm recordings: %v", err))
 			}
+
+			// Exported clips past export_ttl
+			if err := removeExpiredExports(checkCtx); err != nil {
+				span.RecordError(err)
+				pkg.LogWarnContext(checkCtx, fmt.Sprintf("failed to remove expired exports: %v", err))
+			}
+			span.End()
 		}
 	}()
+}
 
-	// Prevent the main routine from exiting
-	select {}
+// pooledNodes returns the media nodes which are not removed from the pool
+func pooledNodes(ctx context.Context) []string {
+	repo := repository.NewNode(ctx)
+	defer repo.Close()
+
+	var nodes []string
+	for _, name := range media.Nodes() {
+		if node := repo.FindByName(name); node != nil && node.State == domain.NodeRemoved {
+			continue
+		}
+		nodes = append(nodes, name)
+	}
 
+	return nodes
 }
@@ -0,0 +1,167 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"stream-session-api/internal/media"
+	"stream-session-api/internal/repository"
+	"stream-session-api/internal/session"
+	"stream-session-api/pkg"
+	"sync"
+	"time"
+)
+
+var (
+	recoveryMu   sync.Mutex                   // Serialize recovery between the watcher and the session check
+	mediaStarted = make(map[string]string)    // Start time of each media node instance last seen
+	recoveredAt  = make(map[string]time.Time) // Time each media node was last recovered
+)
+
+// recoveryGrace is the time given to readers to reconnect to recovered paths
+// before their sessions are reaped as inactive
+const recoveryGrace = time.Minute
+
+// RecoverStreamSessions re-creates the media server paths of non-expired
+// streams that are missing, e.g. after a media node restarted.
+func RecoverStreamSessions(ctx context.Context) error {
+	recoveryMu.Lock()
+	defer recoveryMu.Unlock()
+
+	var errs []error
+	for _, node := range pooledNodes(ctx) {
+		if err := recoverStreamSessions(ctx, node); err != nil {
+			errs = append(errs, fmt.Errorf("media node %s: %w", node, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func recoverStreamSessions(ctx context.Context, node string) error {
+	pkg.LogInfoContext(ctx, fmt.Sprintf("recover stream sessions of media node %s...", node))
+
+	backend := media.Node(node)
+	info, err := backend.Info(ctx)
+	if err != nil {
+		return pkg.NewError(pkg.ErrProcessFail, err)
+	}
+
+	repo := repository.NewStream(ctx)
+	defer repo.Close()
+
+	streams, err := repo.GetAll()
+	if err != nil {
+		return pkg.NewError(pkg.ErrProcessFail, err)
+	}
+
+	now := time.Now()
+	origins := make(map[string]bool)
+	transcoders := make(map[string]bool)
+	for _, stream := range streams {
+		// Re-add origin path relayed by the stream
+		if stream.Origin == node && !stream.Expired(now) && !origins[stream.Id] {
+			origins[stream.Id] = true
+			if err := session.RecoverOrigin(ctx, stream); err != nil {
+				pkg.LogWarnContext(ctx, fmt.Sprintf("failed to recover origin of stream %s: %v", stream.Id, err))
+			}
+		}
+
+		// Re-add transcoder path read by the stream
+		path := session.TranscodePath(stream.Id, stream.Profile)
+		if stream.Origin == node && stream.Profile != "" && !stream.Expired(now) && !transcoders[path] {
+			transcoders[path] = true
+			if err := session.RecoverTranscoder(ctx, stream); err != nil {
+				pkg.LogWarnContext(ctx, fmt.Sprintf("failed to recover transcoder %s: %v", path, err))
+			}
+		}
+
+		if media.NodeName(stream.Node) != node || stream.Expired(now) || stream.Source == "" {
+			continue
+		}
+
+		// Skip path still known by the media server
+		_, err := backend.PathStatus(ctx, stream.Uuid)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, pkg.ErrNotFound) {
+			pkg.LogWarnContext(ctx, fmt.Sprintf("failed to check path stream %s: %v", stream.Uuid, err))
+			continue
+		}
+
+		// Re-add stream path
+		if err := backend.CreatePath(ctx, stream.Uuid, stream.Source); err != nil {
+			pkg.LogWarnContext(ctx, fmt.Sprintf("failed to recover path stream %s: %v", stream.Uuid, err))
+			continue
+		}
+		pkg.LogInfoContext(ctx, fmt.Sprintf("%v recovered", *stream))
+	}
+
+	// Remember the media node instance once recovered
+	mediaStarted[node] = info.Started
+	recoveredAt[node] = time.Now()
+
+	return nil
+}
+
+// recentlyRecovered reports whether node was recovered within the grace period
+func recentlyRecovered(node string, now time.Time) bool {
+	recoveryMu.Lock()
+	defer recoveryMu.Unlock()
+
+	return now.Sub(recoveredAt[node]) < recoveryGrace
+}
+
+// recoverIfRestarted runs the recovery of a node when its instance changed
+func recoverIfRestarted(ctx context.Context, node string) error {
+	recoveryMu.Lock()
+	defer recoveryMu.Unlock()
+
+	info, err := media.Node(node).Info(ctx)
+	if err != nil {
+		return pkg.NewError(pkg.ErrProcessFail, err)
+	}
+	if info.Started == mediaStarted[node] {
+		return nil
+	}
+
+	pkg.LogWarnContext(ctx, fmt.Sprintf("media node %s restarted at %s", node, info.Started))
+	return recoverStreamSessions(ctx, node)
+}
+
+// MediaServerRestartCheck watches the media node instances and recovers the
+// stream sessions of a restarted one, it stops when ctx is done.
+func MediaServerRestartCheck(ctx context.Context) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		val, _ := strconv.ParseInt(os.Getenv("PERIODIC_MEDIA_SERVER_CHECK"), 10, 16)
+		if val <= 0 {
+			val = 10
+		}
+		ticker := time.NewTicker(time.Second * time.Duration(val))
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				pkg.LogInfo("media server check stopped")
+				return
+			case <-ticker.C:
+			}
+
+			checkCtx, span := pkg.StartSpan(context.WithoutCancel(ctx), "MediaServerRestartCheck")
+			for _, node := range pooledNodes(checkCtx) {
+				if err := recoverIfRestarted(checkCtx, node); err != nil {
+					span.RecordError(err)
+					pkg.LogWarnContext(checkCtx, fmt.Sprintf("failed to check media node %s: %v", node, err))
+				}
+			}
+			span.End()
+		}
+	}()
+}
@@ -0,0 +1,102 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"stream-session-api/domain"
+	"stream-session-api/internal/repository"
+	"stream-session-api/internal/session"
+	"stream-session-api/pkg"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// retentionHandler deletes the expired segments of the recordings which are
+// not under legal hold and reports the run
+func retentionHandler(ctx context.Context) (*domain.RetentionReport, error) {
+	repo := repository.NewRecording(ctx)
+	defer repo.Close()
+
+	recordings, err := repo.GetAll()
+	if err != nil {
+		return nil, pkg.NewError(pkg.ErrProcessFail, err)
+	}
+
+	report := &domain.RetentionReport{StartedAt: time.Now()}
+	for _, recording := range recordings {
+		if !recording.PurgedAt.IsZero() {
+			continue
+		}
+		report.Recordings++
+
+		if recording.Hold != nil {
+			report.Held++
+			continue
+		}
+		days := session.RetentionDays(recording)
+		if days == 0 {
+			continue
+		}
+
+		cutoff := report.StartedAt.Add(-time.Hour * 24 * time.Duration(days))
+		segments, bytes, err := session.PurgeRecording(ctx, recording, cutoff)
+		report.Segments += segments
+		report.Bytes += bytes
+		if err != nil {
+			pkg.LogWarnContext(ctx, fmt.Sprintf("failed to purge recording %s: %v", recording.Id, err))
+			report.Errors = append(report.Errors, fmt.Sprintf("recording %s: %v", recording.Id, err))
+		}
+	}
+	report.FinishedAt = time.Now()
+
+	if err := repo.SaveReport(report); err != nil {
+		return report, pkg.NewError(pkg.ErrProcessFail, err)
+	}
+
+	return report, nil
+}
+
+// PeriodicRetentionCheck starts the retention loop, it stops when ctx is done.
+// An in-flight run is not cancelled, Shutdown waits for it.
+func PeriodicRetentionCheck(ctx context.Context) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		val, _ := strconv.ParseInt(os.Getenv("PERIODIC_RETENTION_CHECK"), 10, 32)
+		if val <= 0 {
+			val = 3600
+		}
+		ticker := time.NewTicker(time.Second * time.Duration(val))
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				pkg.LogInfo("retention check stopped")
+				return
+			case <-ticker.C:
+			}
+
+			checkCtx, span := pkg.StartSpan(context.WithoutCancel(ctx), "PeriodicRetentionCheck")
+			report, err := retentionHandler(checkCtx)
+			if err != nil {
+				span.RecordError(err)
+				pkg.LogWarnContext(checkCtx, fmt.Sprintf("failed to check recording retention: %v", err))
+			}
+			if report != nil {
+				span.SetAttributes(
+					attribute.Int("retention.segments", report.Segments),
+					attribute.Int64("retention.bytes", report.Bytes),
+					attribute.Int("retention.errors", len(report.Errors)),
+				)
+				pkg.LogInfoContext(checkCtx, fmt.Sprintf("retention: %d segments deleted, %d bytes reclaimed, %d recordings held, %d errors",
+					report.Segments, report.Bytes, report.Held, len(report.Errors)))
+			}
+			span.End()
+		}
+	}()
+}
@@ -0,0 +1,92 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"stream-session-api/internal/media"
+	"stream-session-api/internal/repository"
+	"stream-session-api/internal/session"
+	"stream-session-api/pkg"
+	"sync"
+)
+
+// Session policy applied on shutdown (SHUTDOWN_SESSION_POLICY)
+const (
+	SessionPolicyKeep     = "keep"     // Leave stream paths and records as they are
+	SessionPolicyTeardown = "teardown" // Remove every stream path created by this instance
+)
+
+// Workers started by this package
+var wg sync.WaitGroup
+
+// Shutdown stops the gRPC and HTTP servers and workers, applies the session policy
+// and closes the redis pool. Steps still running when ctx is done are abandoned.
+func Shutdown(ctx context.Context) {
+	pkg.LogInfo("shutting down...")
+
+	// Stop accepting rpcs and requests and wait for in-flight ones
+	StopGrpcServer(ctx)
+	StopHttpServer(ctx)
+
+	// Wait for an in-flight session check
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		pkg.LogWarn("workers did not stop before shutdown deadline")
+	}
+
+	// Apply session policy
+	policy := os.Getenv("SHUTDOWN_SESSION_POLICY")
+	switch policy {
+	case "", SessionPolicyKeep:
+		pkg.LogInfo("keep stream sessions")
+	case SessionPolicyTeardown:
+		if err := teardownSessions(ctx); err != nil {
+			pkg.LogError(fmt.Sprintf("failed to teardown stream sessions: %v", err))
+		}
+	default:
+		pkg.LogWarn(fmt.Sprintf("unknown shutdown session policy %q, keep stream sessions", policy))
+	}
+
+	// Stop in-process media server
+	media.Close()
+
+	// Close redis pool
+	if err := repository.Shutdown(); err != nil {
+		pkg.LogError(fmt.Sprintf("failed to close redis: %v", err))
+	}
+}
+
+// teardownSessions removes the stream paths created by this instance
+func teardownSessions(ctx context.Context) error {
+	pkg.LogInfo("teardown stream sessions...")
+
+	repo := repository.NewStream(ctx)
+	defer repo.Close()
+
+	streams, err := repo.GetAll()
+	if err != nil {
+		return pkg.NewError(pkg.ErrProcessFail, err)
+	}
+
+	for _, stream := range streams {
+		if stream.Instance != pkg.InstanceId() {
+			continue
+		}
+
+		// Stop stream path and delete stream redis log
+		if err := session.Remove(ctx, stream); err != nil {
+			pkg.LogWarn(fmt.Sprintf("failed to remove stream %s: %v", stream.Uuid, err))
+			continue
+		}
+		pkg.LogInfo(fmt.Sprintf("%v removed", *stream))
+	}
+
+	return nil
+}
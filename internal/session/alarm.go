@@ -0,0 +1,222 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"stream-session-api/domain"
+	"stream-session-api/internal/conf/network"
+	"stream-session-api/internal/media"
+	"stream-session-api/internal/placement"
+	"stream-session-api/internal/repository"
+	"stream-session-api/pkg"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// bufferLag is the delay after which a continuous buffer is considered stopped
+const bufferLag = 5 * time.Second
+
+// AlarmSource returns the config of an alarm source
+func AlarmSource(source string) (network.AlarmSource, bool) {
+	for _, alarm := range network.Get().Alarms.Sources {
+		if alarm.Name == source {
+			return alarm, true
+		}
+	}
+	return network.AlarmSource{}, false
+}
+
+// TriggerAlarm records the origin paths of the cameras of an event source
+// from pre-roll before the event to post-roll after it. A path already
+// recorded, e.g. by a continuous buffer, only keeps the window.
+func TriggerAlarm(ctx context.Context, event *domain.AlarmEvent) ([]*domain.Recording, error) {
+	if !isMediaMtx() {
+		return nil, pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("media backend does not support recording"))
+	}
+
+	source, ok := AlarmSource(event.Source)
+	if !ok {
+		return nil, pkg.NewError(pkg.ErrNotFound, fmt.Errorf("alarm source %s not found", event.Source))
+	}
+
+	// An event from the future is clamped to its reception
+	now := time.Now()
+	if event.Time.IsZero() || event.Time.After(now) {
+		event.Time = now
+	}
+
+	tags := []string{"alarm", "alarm:" + event.Source, "event:" + event.Type}
+	if event.Id != "" {
+		tags = append(tags, "event-id:"+event.Id)
+	}
+
+	var result []*domain.Recording
+	var err error
+	for _, streamId := range source.StreamIds {
+		var recording *domain.Recording
+		recording, err = alarmRecording(ctx, streamId, event, tags, source.Viewers)
+		if err != nil {
+			break
+		}
+		result = append(result, recording)
+		pkg.LogInfoContext(ctx, fmt.Sprintf("alarm %s of %s records %s until %s", event.Type, event.Source, streamId, recording.StopAt.Format(time.RFC3339)))
+	}
+
+	// Stop the recordings once their post-roll is over
+	if len(result) > 0 {
+		postRoll := time.Until(result[0].StopAt)
+		time.AfterFunc(postRoll, func() {
+			if err := StopDueRecordings(context.WithoutCancel(ctx)); err != nil {
+				pkg.LogWarnContext(ctx, fmt.Sprintf("failed to stop alarm recordings: %v", err))
+			}
+		})
+	}
+
+	return result, err
+}
+
+// alarmRecording starts or extends the alarm recording of a camera, seen by
+// the viewers of the sources of its events
+func alarmRecording(ctx context.Context, streamId string, event *domain.AlarmEvent, tags, viewers []string) (*domain.Recording, error) {
+	conf := network.Get()
+
+	origin, err := alarmOrigin(ctx, streamId)
+	if err != nil {
+		return nil, err
+	}
+	// The recording holds the origin path until it stops
+	if err := acquireOrigin(ctx, origin, streamId); err != nil {
+		return nil, err
+	}
+
+	start := event.Time.Add(-time.Second * time.Duration(conf.Alarms.PreRoll))
+	stop := event.Time.Add(time.Second * time.Duration(conf.Alarms.PostRoll))
+
+	repo := repository.NewRecording(ctx)
+	defer repo.Close()
+
+	recordings, err := repo.GetAll()
+	if err != nil {
+		return nil, releaseAlarmOrigin(ctx, origin.Name, streamId, pkg.NewError(pkg.ErrProcessFail, err))
+	}
+
+	// Overlapping events extend the running alarm recording
+	recorded := false
+	for _, other := range recordings {
+		if !other.Active() || other.Node != origin.Name || other.Path != OriginPath(streamId) {
+			continue
+		}
+		if other.Owner != domain.AlarmOwner {
+			recorded = true
+			continue
+		}
+		if stop.After(other.StopAt) {
+			other.StopAt = stop
+		}
+		if start.Before(other.StartedAt) {
+			other.StartedAt = start
+		}
+		other.Tags = mergeTags(other.Tags, tags)
+		other.Viewers = mergeTags(other.Viewers, viewers)
+		if err := repo.Insert(other); err != nil {
+			return nil, releaseAlarmOrigin(ctx, origin.Name, streamId, pkg.NewError(pkg.ErrProcessFail, err))
+		}
+		return other, releaseOriginOf(ctx, origin.Name, streamId)
+	}
+
+	recording := &domain.Recording{
+		Id:              uuid.New().String(),
+		Owner:           domain.AlarmOwner,
+		StreamId:        streamId,
+		Node:            origin.Name,
+		Path:            OriginPath(streamId),
+		Source:          originSource(origin, streamId),
+		Format:          conf.Recording.Format,
+		SegmentDuration: conf.Recording.SegmentDuration,
+		PathPattern:     conf.Recording.Path,
+		StartedAt:       start,
+		StopAt:          stop,
+		Tags:            tags,
+		Viewers:         viewers,
+		Window:          recorded,
+	}
+
+	// The continuous buffer of the path holds the pre-roll
+	backend := media.Node(origin.Name)
+	if !recording.Window {
+		spans, err := backend.ListRecordSpans(ctx, recording.Path, start, time.Now())
+		if err != nil {
+			pkg.LogWarnContext(ctx, fmt.Sprintf("failed to list spans of %s on %s: %v", recording.Path, origin.Name, err))
+		}
+		if len(spans) > 0 {
+			last := spans[len(spans)-1]
+			recording.Window = !last.Start.Add(last.Duration).Before(time.Now().Add(-bufferLag))
+		}
+	}
+	// Else only the post-roll is recorded
+	if !recording.Window {
+		options := recordOptions(recording)
+		if err := backend.SetRecord(ctx, recording.Path, &options); err != nil {
+			return nil, releaseAlarmOrigin(ctx, origin.Name, streamId, err)
+		}
+	}
+
+	if err := repo.Insert(recording); err != nil {
+		return nil, releaseAlarmOrigin(ctx, origin.Name, streamId, pkg.NewError(pkg.ErrProcessFail, err))
+	}
+
+	return recording, nil
+}
+
+// alarmOrigin returns the node of the origin path of a camera: the origin
+// node of its site, the one relayed by its sessions, else a placed node
+func alarmOrigin(ctx context.Context, streamId string) (network.MediaMtx, error) {
+	conf := network.Get()
+	if origin, ok := conf.Origin(streamId); ok {
+		return origin, nil
+	}
+
+	repo := repository.NewStream(ctx)
+	defer repo.Close()
+
+	streams, err := repo.GetAll()
+	if err != nil {
+		return network.MediaMtx{}, pkg.NewError(pkg.ErrProcessFail, err)
+	}
+	for _, stream := range streams {
+		if stream.Id == streamId && stream.Origin != "" {
+			return conf.Node(stream.Origin), nil
+		}
+	}
+
+	unavailable, err := UnavailableNodes(ctx)
+	if err != nil {
+		return network.MediaMtx{}, pkg.NewError(pkg.ErrProcessFail, err)
+	}
+	name, err := placement.Place(ctx, streamId, streams, unavailable)
+	if err != nil {
+		return network.MediaMtx{}, err
+	}
+
+	return conf.Node(name), nil
+}
+
+// releaseAlarmOrigin drops the reference taken by a failed alarm recording
+func releaseAlarmOrigin(ctx context.Context, node, streamId string, err error) error {
+	if err := releaseOriginOf(ctx, node, streamId); err != nil {
+		pkg.LogWarnContext(ctx, fmt.Sprintf("failed to release origin %s of %s: %v", node, streamId, err))
+	}
+	return err
+}
+
+// mergeTags appends the values of more missing from tags
+func mergeTags(tags, more []string) []string {
+	for _, tag := range more {
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
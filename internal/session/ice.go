@@ -0,0 +1,48 @@
+package session
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"stream-session-api/domain"
+	"stream-session-api/internal/conf/network"
+	"time"
+)
+
+// IceServers returns the stun and turn servers of a stream session. Turn
+// credentials follow the coturn rest api scheme and expire with the session.
+func IceServers(stream *domain.Stream) []domain.IceServer {
+	conf := network.Get().Ice
+
+	var servers []domain.IceServer
+	if len(conf.StunUrls) > 0 {
+		servers = append(servers, domain.IceServer{Urls: conf.StunUrls})
+	}
+	if len(conf.TurnUrls) == 0 || conf.TurnSecret == "" {
+		return servers
+	}
+
+	// Sessions which never expire get a fixed lifetime
+	expiresAt := stream.ExpiresAt
+	if expiresAt.IsZero() {
+		ttl := conf.TurnTtl
+		if ttl == 0 {
+			ttl = 86400
+		}
+		expiresAt = time.Now().Add(time.Second * time.Duration(ttl))
+	}
+
+	// username = "<expiry timestamp>:<user>", credential = base64(hmac-sha1(secret, username))
+	username := fmt.Sprintf("%d:%s", expiresAt.Unix(), stream.Owner)
+	mac := hmac.New(sha1.New, []byte(conf.TurnSecret))
+	mac.Write([]byte(username))
+
+	servers = append(servers, domain.IceServer{
+		Urls:       conf.TurnUrls,
+		Username:   username,
+		Credential: base64.StdEncoding.EncodeToString(mac.Sum(nil)),
+	})
+
+	return servers
+}
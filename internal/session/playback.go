@@ -0,0 +1,323 @@
+package session
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"stream-session-api/domain"
+	"stream-session-api/internal/conf/network"
+	"stream-session-api/internal/evidence"
+	"stream-session-api/internal/media"
+	"stream-session-api/internal/repository"
+	"stream-session-api/pkg"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ListRecordings returns the recorded spans of the recordings visible to
+// owner for streamId between start and end, holding every tag of tags
+func ListRecordings(ctx context.Context, owner, streamId string, start, end time.Time, tags []string) ([]domain.RecordSpan, error) {
+	repo := repository.NewRecording(ctx)
+	defer repo.Close()
+
+	recordings, err := repo.GetAll()
+	if err != nil {
+		return nil, pkg.NewError(pkg.ErrProcessFail, err)
+	}
+
+	var result []domain.RecordSpan
+	for _, recording := range recordings {
+		if !recording.VisibleTo(owner) || recording.StreamId != streamId {
+			continue
+		}
+		if slices.ContainsFunc(tags, func(tag string) bool { return !slices.Contains(recording.Tags, tag) }) {
+			continue
+		}
+
+		// Part of the range covered by the recording
+		from, to := recordWindow(recording)
+		if from.Before(start) {
+			from = start
+		}
+		if to.After(end) {
+			to = end
+		}
+		if !from.Before(to) {
+			continue
+		}
+
+		// A path may hold the spans of other recordings, keep this one's
+		spans, err := media.Node(recording.Node).ListRecordSpans(ctx, recording.Path, from, to)
+		if err != nil {
+			return nil, err
+		}
+		for _, span := range spans {
+			spanEnd := span.Start.Add(span.Duration)
+			if span.Start.Before(from) {
+				span.Start = from
+			}
+			if spanEnd.After(to) {
+				spanEnd = to
+			}
+			if !span.Start.Before(spanEnd) {
+				continue
+			}
+			span.Recording = recording.Id
+			span.Tags = recording.Tags
+			span.Duration = spanEnd.Sub(span.Start)
+			result = append(result, span)
+		}
+	}
+
+	return result, nil
+}
+
+// SignRecordingURL returns the signed playback url of a window of a
+// recording and its expiry
+func SignRecordingURL(recording *domain.Recording, start time.Time, duration time.Duration) (string, time.Time, error) {
+	if err := checkWindow(recording, start, duration); err != nil {
+		return "", time.Time{}, err
+	}
+
+	conf := network.Get()
+	if conf.Recording.Secret == "" {
+		return "", time.Time{}, pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("recording url secret not configured"))
+	}
+	expires := time.Now().Add(time.Second * time.Duration(conf.Recording.UrlTtl))
+
+	query := url.Values{}
+	query.Set("start", start.Format(time.RFC3339Nano))
+	query.Set("duration", strconv.FormatFloat(duration.Seconds(), 'f', -1, 64))
+	query.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	query.Set("signature", recordingSignature(recording.Id, query))
+
+	return fmt.Sprintf("%s/recordings/%s/get?%s", conf.Http.BaseUrl(), recording.Id, query.Encode()), expires, nil
+}
+
+// VerifyRecordingURL checks the signature and expiry of a playback url and
+// returns the recording and its window
+func VerifyRecordingURL(ctx context.Context, id string, query url.Values) (*domain.Recording, time.Time, time.Duration, error) {
+	start, duration, err := verifyRecordingQuery(id, query, time.Now())
+	if err != nil {
+		return nil, time.Time{}, 0, err
+	}
+
+	repo := repository.NewRecording(ctx)
+	defer repo.Close()
+
+	recording := repo.FindById(id)
+	if recording == nil {
+		return nil, time.Time{}, 0, pkg.NewError(pkg.ErrNotFound, fmt.Errorf("recording %s not found", id))
+	}
+
+	return recording, start, duration, nil
+}
+
+// verifyRecordingQuery checks the signature and expiry of the query of a
+// playback url at now and returns its window
+func verifyRecordingQuery(id string, query url.Values, now time.Time) (time.Time, time.Duration, error) {
+	// An empty secret would let anyone sign
+	signature := query.Get("signature")
+	if network.Get().Recording.Secret == "" || !hmac.Equal([]byte(signature), []byte(recordingSignature(id, query))) {
+		return time.Time{}, 0, pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("invalid signature of recording %s", id))
+	}
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || now.Unix() > expires {
+		return time.Time{}, 0, pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("playback url of recording %s expired", id))
+	}
+	start, err := time.Parse(time.RFC3339Nano, query.Get("start"))
+	if err != nil {
+		return time.Time{}, 0, pkg.NewError(pkg.ErrBadRequest, err)
+	}
+	seconds, err := strconv.ParseFloat(query.Get("duration"), 64)
+	if err != nil {
+		return time.Time{}, 0, pkg.NewError(pkg.ErrBadRequest, err)
+	}
+
+	return start, time.Duration(seconds * float64(time.Second)), nil
+}
+
+// ExportClip assembles a window of a recording into a single mp4 file
+func ExportClip(ctx context.Context, owner string, recording *domain.Recording, start time.Time, duration time.Duration) (*domain.Export, error) {
+	if err := checkWindow(recording, start, duration); err != nil {
+		return nil, err
+	}
+
+	source := media.Node(recording.Node).BuildRecordURL(recording.Path, start, duration, "mp4")
+	if source == "" {
+		return nil, pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("playback server of media node %s not configured", recording.Node))
+	}
+
+	conf := network.Get().Recording
+	export := &domain.Export{
+		Id:        uuid.New().String(),
+		Owner:     owner,
+		Recording: recording.Id,
+		StreamId:  recording.StreamId,
+		Start:     start,
+		Duration:  duration,
+		Token:     newToken(),
+		CreatedAt: time.Now(),
+	}
+	export.File = filepath.Join(conf.ExportPath, export.Id+".mp4")
+	export.ExpiresAt = export.CreatedAt.Add(time.Second * time.Duration(conf.ExportTtl))
+
+	// Download the clip from the playback server
+	if err := os.MkdirAll(conf.ExportPath, 0o755); err != nil {
+		return nil, pkg.NewError(pkg.ErrWriteFile, err)
+	}
+	client := pkg.NewHttpClient()
+	resp, err := client.R().
+		SetContext(ctx).
+		SetOutput(export.File).
+		Get(source)
+	if err != nil {
+		os.Remove(export.File)
+		return nil, pkg.NewError(pkg.ErrUnavailable, err)
+	}
+	if resp.StatusCode() != 200 {
+		os.Remove(export.File)
+		err := fmt.Errorf("%d failed to get clip of recording %s", resp.StatusCode(), recording.Id)
+		if resp.StatusCode() == 404 {
+			return nil, pkg.NewError(pkg.ErrNotFound, err)
+		}
+		return nil, pkg.NewError(pkg.ErrUnavailable, err)
+	}
+
+	// Signed manifest next to the clip
+	export.Manifest = filepath.Join(conf.ExportPath, export.Id+".manifest.json")
+	if err := writeManifest(export, recording); err != nil {
+		os.Remove(export.File)
+		return nil, err
+	}
+
+	repo := repository.NewExport(ctx)
+	defer repo.Close()
+
+	if err := repo.Insert(export); err != nil {
+		os.Remove(export.File)
+		os.Remove(export.Manifest)
+		return nil, pkg.NewError(pkg.ErrProcessFail, err)
+	}
+
+	return export, nil
+}
+
+// writeManifest hashes the file of an export and writes its signed manifest
+func writeManifest(export *domain.Export, recording *domain.Recording) error {
+	key, err := evidence.PrivateKey(network.Get().Recording.SigningKey)
+	if err != nil {
+		return pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("invalid signing key: %v", err))
+	}
+
+	file, err := evidence.HashFile(export.File)
+	if err != nil {
+		return pkg.NewError(pkg.ErrReadFile, err)
+	}
+
+	manifest := &evidence.Manifest{
+		Version:   1,
+		ExportId:  export.Id,
+		Recording: recording.Id,
+		StreamId:  export.StreamId,
+		Source:    evidence.Redact(recording.Source),
+		Start:     export.Start.UTC(),
+		End:       export.Start.Add(export.Duration).UTC(),
+		Principal: export.Owner,
+		CreatedAt: export.CreatedAt.UTC(),
+		Files:     []evidence.File{file},
+	}
+	if err := manifest.Sign(key); err != nil {
+		return pkg.NewError(pkg.ErrProcessFail, err)
+	}
+	if err := manifest.Write(export.Manifest); err != nil {
+		return pkg.NewError(pkg.ErrWriteFile, err)
+	}
+
+	return nil
+}
+
+// ExportManifestURL returns the download url of the manifest of an exported clip
+func ExportManifestURL(export *domain.Export) string {
+	return fmt.Sprintf("%s/exports/%s/manifest?token=%s", network.Get().Http.BaseUrl(), export.Id, export.Token)
+}
+
+// ExportURL returns the download url of an exported clip
+func ExportURL(export *domain.Export) string {
+	return fmt.Sprintf("%s/exports/%s?token=%s", network.Get().Http.BaseUrl(), export.Id, export.Token)
+}
+
+// AuthorizeExport returns the exported clip of id if token is its secret
+func AuthorizeExport(ctx context.Context, id, token string) (*domain.Export, error) {
+	repo := repository.NewExport(ctx)
+	defer repo.Close()
+
+	export := repo.FindById(id)
+	if export == nil || time.Now().After(export.ExpiresAt) {
+		return nil, pkg.NewError(pkg.ErrNotFound, fmt.Errorf("export %s not found", id))
+	}
+	if token == "" || subtle.ConstantTimeCompare([]byte(export.Token), []byte(token)) != 1 {
+		return nil, pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("invalid token for export %s", id))
+	}
+
+	return export, nil
+}
+
+// RemoveExport deletes the files and the record of an exported clip
+func RemoveExport(ctx context.Context, export *domain.Export) error {
+	for _, file := range []string{export.File, export.Manifest} {
+		if file == "" {
+			continue
+		}
+		if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return pkg.NewError(pkg.ErrWriteFile, err)
+		}
+	}
+
+	repo := repository.NewExport(ctx)
+	defer repo.Close()
+
+	if err := repo.Delete(export.Id); err != nil {
+		return pkg.NewError(pkg.ErrProcessFail, err)
+	}
+
+	return nil
+}
+
+// recordWindow returns the time range covered by a recording
+func recordWindow(recording *domain.Recording) (time.Time, time.Time) {
+	if recording.Active() {
+		now := time.Now()
+		if !recording.StopAt.IsZero() && recording.StopAt.Before(now) {
+			now = recording.StopAt
+		}
+		return recording.StartedAt, now
+	}
+	return recording.StartedAt, recording.EndedAt
+}
+
+// checkWindow checks a window lies within a recording
+func checkWindow(recording *domain.Recording, start time.Time, duration time.Duration) error {
+	from, to := recordWindow(recording)
+	if duration <= 0 || start.Before(from) || start.Add(duration).After(to) {
+		return pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("window out of recording %s", recording.Id))
+	}
+	return nil
+}
+
+// recordingSignature signs the window and expiry of a playback url
+func recordingSignature(id string, query url.Values) string {
+	mac := hmac.New(sha256.New, []byte(network.Get().Recording.Secret))
+	mac.Write([]byte(id + "\n" + query.Get("start") + "\n" + query.Get("duration") + "\n" + query.Get("expires")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,83 @@
+package session
+
+import (
+	"errors"
+	"net/url"
+	"stream-session-api/domain"
+	"stream-session-api/internal/conf/network"
+	"stream-session-api/pkg"
+	"testing"
+	"time"
+)
+
+func TestRecordingURL(t *testing.T) {
+	conf := network.Get()
+	t.Cleanup(func() { network.Set(conf) })
+
+	signing := conf
+	signing.Recording.Secret = "secret"
+	signing.Recording.UrlTtl = 60
+	signing.Http.PublicUrl = "https://stream.example.com"
+	network.Set(signing)
+
+	started := time.Now().Add(-time.Hour).Truncate(time.Second)
+	recording := &domain.Recording{Id: "recording-1", StartedAt: started, EndedAt: started.Add(30 * time.Minute)}
+	start := started.Add(time.Minute)
+
+	raw, expires, err := SignRecordingURL(recording, start, 2*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signed.Path != "/recordings/recording-1/get" {
+		t.Fatalf("path %s", signed.Path)
+	}
+
+	tests := []struct {
+		name   string
+		id     string
+		modify func(query url.Values)
+		now    time.Time
+		secret string
+		valid  bool
+	}{
+		{name: "valid", id: "recording-1", now: time.Now(), secret: "secret", valid: true},
+		{name: "other recording", id: "recording-2", now: time.Now(), secret: "secret"},
+		{name: "tampered start", id: "recording-1", modify: func(q url.Values) { q.Set("start", started.Format(time.RFC3339Nano)) }, now: time.Now(), secret: "secret"},
+		{name: "tampered duration", id: "recording-1", modify: func(q url.Values) { q.Set("duration", "600") }, now: time.Now(), secret: "secret"},
+		{name: "tampered expiry", id: "recording-1", modify: func(q url.Values) { q.Set("expires", "9999999999") }, now: time.Now(), secret: "secret"},
+		{name: "missing signature", id: "recording-1", modify: func(q url.Values) { q.Del("signature") }, now: time.Now(), secret: "secret"},
+		{name: "expired", id: "recording-1", now: expires.Add(2 * time.Second), secret: "secret"},
+		{name: "wrong secret", id: "recording-1", now: time.Now(), secret: "other"},
+		{name: "no secret", id: "recording-1", now: time.Now(), secret: ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			verifying := signing
+			verifying.Recording.Secret = test.secret
+			network.Set(verifying)
+
+			query := signed.Query()
+			if test.modify != nil {
+				test.modify(query)
+			}
+
+			from, duration, err := verifyRecordingQuery(test.id, query, test.now)
+			if !test.valid {
+				if !errors.Is(err, pkg.ErrBadRequest) {
+					t.Fatalf("got %v, want ErrBadRequest", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !from.Equal(start) || duration != 2*time.Minute {
+				t.Fatalf("window %v %v, want %v %v", from, duration, start, 2*time.Minute)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package session
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"stream-session-api/domain"
+	"stream-session-api/internal/media"
+	"stream-session-api/internal/placement"
+	"stream-session-api/internal/repository"
+	"stream-session-api/pkg"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CreatePublish places a new publish session of owner for streamId, adds
+// its publisher path on the media node and stores it.
+func CreatePublish(ctx context.Context, owner, streamId string) (*domain.Publish, error) {
+	if !isMediaMtx() {
+		return nil, pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("media backend does not support publishing"))
+	}
+
+	publish := &domain.Publish{
+		Id:         streamId,
+		Uuid:       uuid.New().String(),
+		Owner:      owner,
+		Instance:   pkg.InstanceId(),
+		Credential: newToken(),
+		CreatedAt:  time.Now(),
+	}
+
+	// Place publish on an available media node
+	unavailable, err := UnavailableNodes(ctx)
+	if err != nil {
+		return nil, pkg.NewError(pkg.ErrProcessFail, err)
+	}
+	streamRepo := repository.NewStream(ctx)
+	defer streamRepo.Close()
+	streams, err := streamRepo.GetAll()
+	if err != nil {
+		return nil, pkg.NewError(pkg.ErrProcessFail, err)
+	}
+	publish.Node, err = placement.Place(ctx, publish.Id, streams, unavailable)
+	if err != nil {
+		return nil, err
+	}
+
+	// Add publisher path on media server
+	if err := media.Node(publish.Node).CreatePath(ctx, publish.Uuid, "publisher"); err != nil {
+		return nil, err
+	}
+
+	repo := repository.NewPublish(ctx)
+	defer repo.Close()
+
+	if err := repo.Insert(publish); err != nil {
+		return nil, pkg.NewError(pkg.ErrProcessFail, err)
+	}
+
+	return publish, nil
+}
+
+// RemovePublish deletes the media path and the record of a publish session,
+// a path already gone is not an error.
+func RemovePublish(ctx context.Context, publish *domain.Publish) error {
+	if err := media.Node(publish.Node).DeletePath(ctx, publish.Uuid); err != nil && !errors.Is(err, pkg.ErrNotFound) {
+		return err
+	}
+
+	repo := repository.NewPublish(ctx)
+	defer repo.Close()
+
+	if err := repo.Delete(publish.Uuid); err != nil {
+		return pkg.NewError(pkg.ErrProcessFail, err)
+	}
+
+	return nil
+}
+
+// PublishURLs returns the publish urls of a publish session by protocol
+func PublishURLs(publish *domain.Publish) map[string]string {
+	return media.Node(publish.Node).BuildPublishURLs(publish.Uuid, publish.Credential)
+}
+
+// AuthorizePublish checks the one-time credential of a publish session,
+// it is bound to the address of the first publisher using it.
+func AuthorizePublish(ctx context.Context, uuid, credential, address string) error {
+	repo := repository.NewPublish(ctx)
+	defer repo.Close()
+
+	publish := repo.FindByUuid(uuid)
+	if publish == nil {
+		return pkg.NewError(pkg.ErrNotFound, fmt.Errorf("publish session %s not found", uuid))
+	}
+	if credential == "" || subtle.ConstantTimeCompare([]byte(publish.Credential), []byte(credential)) != 1 {
+		return pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("invalid credential for publish session %s", uuid))
+	}
+
+	first, err := repo.Consume(uuid, address)
+	if err != nil {
+		return pkg.NewError(pkg.ErrProcessFail, err)
+	}
+	if !first {
+		return pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("credential of publish session %s already used", uuid))
+	}
+
+	return nil
+}
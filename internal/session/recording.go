@@ -0,0 +1,253 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"stream-session-api/domain"
+	"stream-session-api/internal/conf/network"
+	"stream-session-api/internal/media"
+	"stream-session-api/internal/repository"
+	"stream-session-api/pkg"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StartRecording records the path of a stream session, or its shared origin
+// path. Empty format and zero segment default to the recording config.
+func StartRecording(ctx context.Context, owner string, stream *domain.Stream, origin bool, format string, segment time.Duration, tags []string) (*domain.Recording, error) {
+	if !isMediaMtx() {
+		return nil, pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("media backend does not support recording"))
+	}
+
+	conf := network.Get().Recording
+	recording := &domain.Recording{
+		Id:              uuid.New().String(),
+		Owner:           owner,
+		StreamId:        stream.Id,
+		Stream:          stream.Uuid,
+		Node:            stream.Node,
+		Path:            stream.Uuid,
+		Source:          stream.Source,
+		Format:          conf.Format,
+		SegmentDuration: conf.SegmentDuration,
+		PathPattern:     conf.Path,
+		StartedAt:       time.Now(),
+		Tags:            tags,
+	}
+	if origin {
+		if stream.Origin == "" {
+			return nil, pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("stream session %s has no origin path", stream.Uuid))
+		}
+		recording.Node = stream.Origin
+		recording.Path = OriginPath(stream.Id)
+	}
+	// The camera behind the origin path
+	if stream.Origin != "" {
+		recording.Source = originSource(network.Get().Node(stream.Origin), stream.Id)
+	}
+	if format != "" {
+		recording.Format = format
+	}
+	if segment > 0 {
+		recording.SegmentDuration = uint(segment / time.Second)
+	}
+
+	repo := repository.NewRecording(ctx)
+	defer repo.Close()
+
+	// A path is recorded once
+	recordings, err := repo.GetAll()
+	if err != nil {
+		return nil, pkg.NewError(pkg.ErrProcessFail, err)
+	}
+	for _, other := range recordings {
+		if other.Active() && !other.Window && other.Node == recording.Node && other.Path == recording.Path {
+			return nil, pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("path %s already recorded by %s", recording.Path, other.Id))
+		}
+	}
+
+	options := recordOptions(recording)
+	if err := media.Node(recording.Node).SetRecord(ctx, recording.Path, &options); err != nil {
+		return nil, err
+	}
+
+	if err := repo.Insert(recording); err != nil {
+		return nil, pkg.NewError(pkg.ErrProcessFail, err)
+	}
+
+	return recording, nil
+}
+
+// StopRecording stops an active recording and stores its segment files
+func StopRecording(ctx context.Context, recording *domain.Recording) error {
+	return stopRecording(ctx, recording, time.Time{})
+}
+
+// stopRecording stops a recording once among concurrent stops. A recording
+// stopped meanwhile, or extended past a non-zero due, is left as is.
+func stopRecording(ctx context.Context, recording *domain.Recording, due time.Time) error {
+	repo := repository.NewRecording(ctx)
+	defer repo.Close()
+
+	claimed, err := repo.ClaimStop(recording.Id)
+	if err != nil {
+		return pkg.NewError(pkg.ErrProcessFail, err)
+	}
+	if !claimed {
+		return nil
+	}
+	defer func() {
+		if err := repo.ReleaseStop(recording.Id); err != nil {
+			pkg.LogWarnContext(ctx, fmt.Sprintf("failed to release stop of recording %s: %v", recording.Id, err))
+		}
+	}()
+
+	// The recording may have been stopped or extended meanwhile
+	current := repo.FindById(recording.Id)
+	if current == nil || !current.Active() {
+		return nil
+	}
+	if !due.IsZero() && (current.StopAt.IsZero() || due.Before(current.StopAt)) {
+		return nil
+	}
+	*recording = *current
+
+	backend := media.Node(recording.Node)
+
+	// A window recording of the path takes over the recording
+	if !recording.Window {
+		if other := windowRecording(repo, recording); other != nil {
+			other.Window = false
+			if err := repo.Insert(other); err != nil {
+				return pkg.NewError(pkg.ErrProcessFail, err)
+			}
+			recording.Window = true
+		}
+	}
+
+	// A path already gone is no longer recorded
+	if !recording.Window {
+		if err := backend.SetRecord(ctx, recording.Path, nil); err != nil && !errors.Is(err, pkg.ErrNotFound) {
+			return err
+		}
+	}
+	recording.EndedAt = time.Now()
+	if !recording.StopAt.IsZero() && recording.StopAt.Before(recording.EndedAt) {
+		recording.EndedAt = recording.StopAt
+	}
+
+	// Segments written while recording
+	segments, err := backend.RecordSegments(ctx, recording.Path, recordOptions(recording))
+	if err != nil && !errors.Is(err, pkg.ErrNotFound) {
+		pkg.LogWarnContext(ctx, fmt.Sprintf("failed to list segments of recording %s: %v", recording.Id, err))
+	}
+	recording.Files = nil
+	for _, segment := range windowSegments(recording, segments) {
+		recording.Files = append(recording.Files, segment.File)
+	}
+
+	if err := repo.Insert(recording); err != nil {
+		return pkg.NewError(pkg.ErrProcessFail, err)
+	}
+
+	// Alarm recordings kept the origin path alive
+	if !recording.StopAt.IsZero() {
+		return releaseOriginOf(ctx, recording.Node, recording.StreamId)
+	}
+
+	return nil
+}
+
+// windowRecording returns another active window recording of the path of a
+// recording, nil if none
+func windowRecording(repo domain.RecordingRepository, recording *domain.Recording) *domain.Recording {
+	recordings, err := repo.GetAll()
+	if err != nil {
+		return nil
+	}
+	for _, other := range recordings {
+		if other.Id != recording.Id && other.Active() && other.Window && other.Node == recording.Node && other.Path == recording.Path {
+			return other
+		}
+	}
+	return nil
+}
+
+// StopDueRecordings stops the alarm recordings past their post-roll
+func StopDueRecordings(ctx context.Context) error {
+	repo := repository.NewRecording(ctx)
+	defer repo.Close()
+
+	recordings, err := repo.GetAll()
+	if err != nil {
+		return pkg.NewError(pkg.ErrProcessFail, err)
+	}
+
+	// A failed stop is retried by the next check
+	var errs []error
+	now := time.Now()
+	for _, recording := range recordings {
+		if !recording.Active() || recording.StopAt.IsZero() || now.Before(recording.StopAt) {
+			continue
+		}
+		if err := stopRecording(ctx, recording, now); err != nil {
+			errs = append(errs, fmt.Errorf("recording %s: %w", recording.Id, err))
+			continue
+		}
+		pkg.LogInfoContext(ctx, fmt.Sprintf("alarm recording %s of %s stopped", recording.Id, recording.StreamId))
+	}
+
+	return errors.Join(errs...)
+}
+
+// windowSegments returns the segments overlapping the window of a recording,
+// a segment lasts until the next one
+func windowSegments(recording *domain.Recording, segments []domain.RecordSegment) []domain.RecordSegment {
+	from, to := recordWindow(recording)
+
+	var result []domain.RecordSegment
+	for i, segment := range segments {
+		end := segment.Start.Add(time.Second * time.Duration(recording.SegmentDuration))
+		if i+1 < len(segments) && segments[i+1].Start.Before(end) {
+			end = segments[i+1].Start
+		}
+		if segment.Start.After(to) || !end.After(from) {
+			continue
+		}
+		result = append(result, segment)
+	}
+
+	return result
+}
+
+// stopRecordings stops the active recordings of a stream session
+func stopRecordings(ctx context.Context, stream *domain.Stream) error {
+	repo := repository.NewRecording(ctx)
+	defer repo.Close()
+
+	recordings, err := repo.GetAll()
+	if err != nil {
+		return pkg.NewError(pkg.ErrProcessFail, err)
+	}
+	for _, recording := range recordings {
+		if recording.Stream != stream.Uuid || !recording.Active() {
+			continue
+		}
+		if err := StopRecording(ctx, recording); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// recordOptions returns the media server options of a recording
+func recordOptions(recording *domain.Recording) domain.RecordOptions {
+	return domain.RecordOptions{
+		Format:          recording.Format,
+		SegmentDuration: time.Second * time.Duration(recording.SegmentDuration),
+		Path:            recording.PathPattern,
+	}
+}
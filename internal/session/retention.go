@@ -0,0 +1,120 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"stream-session-api/domain"
+	"stream-session-api/internal/conf/network"
+	"stream-session-api/internal/media"
+	"stream-session-api/internal/repository"
+	"stream-session-api/pkg"
+	"time"
+)
+
+// RetentionDays returns the days a recording is kept by the first matching
+// policy, or the default one. 0 keeps it forever.
+func RetentionDays(recording *domain.Recording) uint {
+	conf := network.Get().Retention
+	for _, policy := range conf.Policies {
+		matched := slices.ContainsFunc(policy.Sources, func(pattern string) bool {
+			ok, _ := path.Match(pattern, recording.StreamId)
+			return ok
+		})
+		if matched || slices.ContainsFunc(policy.Tags, func(tag string) bool {
+			return slices.Contains(recording.Tags, tag)
+		}) {
+			return policy.Days
+		}
+	}
+	return conf.DefaultDays
+}
+
+// SetLegalHold puts a recording under legal hold, or releases it with a nil hold
+func SetLegalHold(ctx context.Context, id string, hold *domain.LegalHold) error {
+	repo := repository.NewRecording(ctx)
+	defer repo.Close()
+
+	recording := repo.FindById(id)
+	if recording == nil {
+		return pkg.NewError(pkg.ErrNotFound, fmt.Errorf("recording %s not found", id))
+	}
+	if hold != nil && !recording.PurgedAt.IsZero() {
+		return pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("recording %s already purged", id))
+	}
+
+	recording.Hold = hold
+	if err := repo.Insert(recording); err != nil {
+		return pkg.NewError(pkg.ErrProcessFail, err)
+	}
+
+	return nil
+}
+
+// PurgeRecording deletes the segments of a recording which ended before
+// cutoff, until a legal hold is set. It returns the deleted segments and
+// their bytes, counted when the segment files are readable.
+func PurgeRecording(ctx context.Context, recording *domain.Recording, cutoff time.Time) (int, int64, error) {
+	backend := media.Node(recording.Node)
+
+	segments, err := backend.RecordSegments(ctx, recording.Path, recordOptions(recording))
+	if err != nil && !errors.Is(err, pkg.ErrNotFound) {
+		return 0, 0, err
+	}
+
+	repo := repository.NewRecording(ctx)
+	defer repo.Close()
+
+	from, to := recordWindow(recording)
+	deleted, remaining := 0, 0
+	var bytes int64
+	for _, segment := range segments {
+		// Segments of other recordings of the path
+		if segment.Start.Before(from.Add(-time.Second)) || segment.Start.After(to) {
+			continue
+		}
+
+		end := segment.Start.Add(time.Second * time.Duration(recording.SegmentDuration))
+		if end.After(to) {
+			end = to
+		}
+		if !end.Before(cutoff) {
+			remaining++
+			continue
+		}
+
+		// Reload, a legal hold may have been set since the run started
+		if current := repo.FindById(recording.Id); current == nil || current.Hold != nil {
+			pkg.LogInfoContext(ctx, fmt.Sprintf("recording %s held or removed, purge stopped", recording.Id))
+			return deleted, bytes, nil
+		}
+
+		var size int64
+		if info, err := os.Stat(segment.File); err == nil {
+			size = info.Size()
+		}
+		if err := backend.DeleteRecordSegment(ctx, recording.Path, segment.Start); err != nil && !errors.Is(err, pkg.ErrNotFound) {
+			return deleted, bytes, err
+		}
+		deleted++
+		bytes += size
+	}
+
+	// Keep the record of an emptied recording for the audit
+	if remaining == 0 && !recording.Active() {
+		// Reload, a held recording is not marked purged
+		current := repo.FindById(recording.Id)
+		if current == nil || current.Hold != nil {
+			return deleted, bytes, nil
+		}
+		current.PurgedAt = time.Now()
+		if err := repo.Insert(current); err != nil {
+			return deleted, bytes, pkg.NewError(pkg.ErrProcessFail, err)
+		}
+	}
+
+	return deleted, bytes, nil
+}
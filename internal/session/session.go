@@ -0,0 +1,533 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"stream-session-api/domain"
+	"stream-session-api/internal/conf/network"
+	"stream-session-api/internal/media"
+	"stream-session-api/internal/placement"
+	"stream-session-api/internal/repository"
+	"stream-session-api/pkg"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Create places a new stream session of owner for streamId, adds its path
+// on the media node and stores it. Draining nodes and nodes in exclude
+// take no new session. A profile other than empty transcodes the camera.
+func Create(ctx context.Context, owner, streamId, profile string, exclude map[string]bool) (*domain.Stream, error) {
+	// Stream request for specific id
+	stream := &domain.Stream{
+		Id:        streamId,
+		Uuid:      uuid.New().String(),
+		Owner:     owner,
+		Instance:  pkg.InstanceId(),
+		Token:     newToken(),
+		CreatedAt: time.Now(),
+	}
+
+	// Session lifetime, 0 never expires
+	if ttl, _ := strconv.ParseInt(os.Getenv("STREAM_SESSION_TTL"), 10, 32); ttl > 0 {
+		stream.ExpiresAt = stream.CreatedAt.Add(time.Second * time.Duration(ttl))
+	}
+
+	repo := repository.NewStream(ctx)
+	defer repo.Close()
+
+	// Place stream on an available media node
+	unavailable, err := UnavailableNodes(ctx)
+	if err != nil {
+		return nil, pkg.NewError(pkg.ErrProcessFail, err)
+	}
+	for name := range exclude {
+		unavailable[name] = true
+	}
+	streams, err := repo.GetAll()
+	if err != nil {
+		return nil, pkg.NewError(pkg.ErrProcessFail, err)
+	}
+	stream.Node, err = placement.Place(ctx, stream.Id, streams, unavailable)
+	if err != nil {
+		return nil, err
+	}
+
+	// Relay the origin path pulling the camera once, on the origin node of
+	// its site if any, else on the node of the session
+	if isMediaMtx() {
+		origin, ok := network.Get().Origin(stream.Id)
+		if !ok {
+			origin = network.Get().Node(stream.Node)
+		}
+		if err := acquireOrigin(ctx, origin, stream.Id); err != nil {
+			return nil, err
+		}
+		stream.Origin = origin.Name
+		stream.Source = originURL(origin, stream.Id)
+
+		// Viewers of a profile share its transcoder next to the origin path
+		if profile != "" {
+			options, ok := network.Get().Transcoding.Profile(profile)
+			if !ok {
+				return nil, releaseStream(ctx, stream, pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("unknown profile %s", profile)))
+			}
+			if err := acquireTranscoder(ctx, origin, stream.Id, options); err != nil {
+				return nil, releaseStream(ctx, stream, err)
+			}
+			stream.Profile = profile
+			stream.Source = fmt.Sprintf("rtsp://%s:%d/%s", origin.Rtsp.Ip, origin.Rtsp.Port, TranscodePath(stream.Id, profile))
+		}
+	} else if profile != "" {
+		return nil, pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("media backend does not support transcoding"))
+	} else {
+		stream.Source = cameraSource(network.Get().Node(stream.Node).Rtsp, stream.Id)
+	}
+
+	// Add stream session on media server
+	if err := media.Node(stream.Node).CreatePath(ctx, stream.Uuid, stream.Source); err != nil {
+		return nil, releaseStream(ctx, stream, err)
+	}
+
+	// Insert stream url to redis, an unknown session must not hold its path
+	if err := repo.Insert(stream); err != nil {
+		if err := media.Node(stream.Node).DeletePath(ctx, stream.Uuid); err != nil && !errors.Is(err, pkg.ErrNotFound) {
+			pkg.LogWarnContext(ctx, fmt.Sprintf("failed to delete path of %v: %v", *stream, err))
+		}
+		return nil, releaseStream(ctx, stream, pkg.NewError(pkg.ErrProcessFail, err))
+	}
+
+	return stream, nil
+}
+
+// Find returns a live stream session of owner for streamId with profile, nil if none
+func Find(ctx context.Context, owner, streamId, profile string) (*domain.Stream, error) {
+	repo := repository.NewStream(ctx)
+	defer repo.Close()
+
+	streams, err := repo.GetAll()
+	if err != nil {
+		return nil, pkg.NewError(pkg.ErrProcessFail, err)
+	}
+
+	now := time.Now()
+	for _, stream := range streams {
+		if stream.Owner == owner && stream.Id == streamId && stream.Profile == profile && !stream.Expired(now) {
+			return stream, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Authorize returns the live stream session of uuid if token is its secret
+func Authorize(ctx context.Context, uuid, token string) (*domain.Stream, error) {
+	repo := repository.NewStream(ctx)
+	defer repo.Close()
+
+	stream := repo.FindByUuid(uuid)
+	if stream == nil || stream.Expired(time.Now()) {
+		return nil, pkg.NewError(pkg.ErrNotFound, fmt.Errorf("stream session %s not found", uuid))
+	}
+	if token == "" || subtle.ConstantTimeCompare([]byte(stream.Token), []byte(token)) != 1 {
+		return nil, pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("invalid token for stream session %s", uuid))
+	}
+
+	return stream, nil
+}
+
+// AuthorizeRead checks a media path is read through a live stream session
+// or publish session, their uuids are the only paths handed to clients
+func AuthorizeRead(ctx context.Context, path string) error {
+	repo := repository.NewStream(ctx)
+	defer repo.Close()
+
+	if stream := repo.FindByUuid(path); stream != nil && !stream.Expired(time.Now()) {
+		return nil
+	}
+
+	publishRepo := repository.NewPublish(ctx)
+	defer publishRepo.Close()
+
+	if publish := publishRepo.FindByUuid(path); publish != nil {
+		return nil
+	}
+
+	return pkg.NewError(pkg.ErrNotFound, fmt.Errorf("no session reads path %s", path))
+}
+
+// TouchViewer records the activity of a viewer of a stream session,
+// it is forgotten after VIEWER_TIMEOUT seconds without activity
+func TouchViewer(ctx context.Context, viewer *domain.Viewer) error {
+	timeout, _ := strconv.ParseInt(os.Getenv("VIEWER_TIMEOUT"), 10, 32)
+	if timeout <= 0 {
+		timeout = 60
+	}
+
+	repo := repository.NewViewer(ctx)
+	defer repo.Close()
+
+	viewer.SeenAt = time.Now()
+	if err := repo.Save(viewer, time.Second*time.Duration(timeout)); err != nil {
+		return pkg.NewError(pkg.ErrProcessFail, err)
+	}
+
+	return nil
+}
+
+// RemoveViewer forgets a viewer of a stream session
+func RemoveViewer(ctx context.Context, stream *domain.Stream, id string) error {
+	repo := repository.NewViewer(ctx)
+	defer repo.Close()
+
+	if err := repo.Delete(stream.Uuid, id); err != nil {
+		return pkg.NewError(pkg.ErrProcessFail, err)
+	}
+
+	return nil
+}
+
+// Remove deletes the media path and the record of a stream session,
+// a path already gone is not an error.
+func Remove(ctx context.Context, stream *domain.Stream) error {
+	// Recordings end with the session
+	if err := stopRecordings(ctx, stream); err != nil {
+		pkg.LogWarnContext(ctx, fmt.Sprintf("failed to stop recordings of %v: %v", *stream, err))
+	}
+
+	if err := media.Node(stream.Node).DeletePath(ctx, stream.Uuid); err != nil && !errors.Is(err, pkg.ErrNotFound) {
+		return err
+	}
+
+	repo := repository.NewStream(ctx)
+	defer repo.Close()
+
+	if err := repo.Delete(stream.Uuid); err != nil {
+		return pkg.NewError(pkg.ErrProcessFail, err)
+	}
+
+	// A failed transcoder release does not leak the origin
+	return errors.Join(releaseTranscoder(ctx, stream), releaseOrigin(ctx, stream))
+}
+
+// WaitReady polls the path of a stream session until its source is ready
+// with tracks. It returns ErrUnavailable with the reason on timeout.
+func WaitReady(ctx context.Context, stream *domain.Stream, timeout time.Duration) (*domain.PathStatus, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	backend := media.Node(stream.Node)
+	for {
+		path, err := backend.PathStatus(ctx, stream.Uuid)
+		if err == nil && path.Ready && len(path.Tracks) > 0 {
+			return path, nil
+		}
+		// The path may not be listed yet right after it is added
+		if err != nil && ctx.Err() == nil && !errors.Is(err, pkg.ErrUnavailable) && !errors.Is(err, pkg.ErrNotFound) {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, pkg.NewError(pkg.ErrUnavailable, notReadyReason(context.WithoutCancel(ctx), stream, timeout, err))
+		case <-ticker.C:
+		}
+	}
+}
+
+// notReadyReason explains why the source of a stream session is not ready
+func notReadyReason(ctx context.Context, stream *domain.Stream, timeout time.Duration, err error) error {
+	if err != nil {
+		return fmt.Errorf("media node %s: %v", stream.Node, err)
+	}
+
+	// A relayed camera is first pulled by the origin path
+	if stream.Origin != "" {
+		origin, err := media.Node(stream.Origin).PathStatus(ctx, OriginPath(stream.Id))
+		if err == nil && !origin.Ready {
+			return fmt.Errorf("camera %s unreachable from media node %s after %s", stream.Id, stream.Origin, timeout)
+		}
+	}
+
+	return fmt.Errorf("source of stream %s not ready after %s", stream.Id, timeout)
+}
+
+// PlaybackURL returns the url served to the owner of a stream session
+func PlaybackURL(stream *domain.Stream) string {
+	return media.Node(stream.Node).BuildPlaybackURL(stream.Uuid)
+}
+
+// PlaybackURLs returns the urls of a stream session by protocol, restricted
+// to protocols when not empty
+func PlaybackURLs(stream *domain.Stream, protocols []string) map[string]string {
+	urls := media.Node(stream.Node).BuildPlaybackURLs(stream.Uuid)
+	if len(protocols) == 0 {
+		return urls
+	}
+
+	subset := make(map[string]string, len(protocols))
+	for _, protocol := range protocols {
+		if url, ok := urls[protocol]; ok {
+			subset[protocol] = url
+		}
+	}
+	return subset
+}
+
+// UnavailableNodes returns the draining and removed media nodes
+func UnavailableNodes(ctx context.Context) (map[string]bool, error) {
+	repo := repository.NewNode(ctx)
+	defer repo.Close()
+
+	nodes, err := repo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	unavailable := make(map[string]bool)
+	for _, node := range nodes {
+		if node.State != domain.NodeActive {
+			unavailable[node.Name] = true
+		}
+	}
+
+	return unavailable, nil
+}
+
+// Migrate re-creates a stream session on another media node, notifies its
+// owner with the new url and removes the old session.
+func Migrate(ctx context.Context, stream *domain.Stream, exclude map[string]bool) (*domain.Stream, error) {
+	migrated, err := Create(ctx, stream.Owner, stream.Id, stream.Profile, exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the remaining lifetime
+	if !stream.ExpiresAt.IsZero() {
+		migrated.ExpiresAt = stream.ExpiresAt
+
+		repo := repository.NewStream(ctx)
+		defer repo.Close()
+		if err := repo.Insert(migrated); err != nil {
+			if err := Remove(ctx, migrated); err != nil {
+				pkg.LogWarnContext(ctx, fmt.Sprintf("failed to remove %v: %v", *migrated, err))
+			}
+			return nil, pkg.NewError(pkg.ErrProcessFail, err)
+		}
+	}
+
+	// Notify the owner
+	events := repository.NewEvent(ctx)
+	defer events.Close()
+
+	err = events.Publish(&domain.Event{
+		Type:     domain.EventStreamMigrated,
+		Owner:    stream.Owner,
+		StreamId: stream.Id,
+		OldUrl:   PlaybackURL(stream),
+		OldNode:  media.NodeName(stream.Node),
+		NewUrl:   PlaybackURL(migrated),
+		NewNode:  media.NodeName(migrated.Node),
+		Time:     time.Now(),
+	})
+	if err != nil {
+		pkg.LogWarnContext(ctx, fmt.Sprintf("failed to notify %s: %v", stream.Owner, err))
+	}
+
+	// Remove the old session
+	if err := Remove(ctx, stream); err != nil {
+		return migrated, err
+	}
+
+	return migrated, nil
+}
+
+// OriginPath returns the name of the origin path pulling the camera of streamId
+func OriginPath(streamId string) string {
+	return "origin-" + streamId
+}
+
+// RecoverOrigin re-creates the origin path relayed by a stream session
+// when it is missing, e.g. after the origin node restarted.
+func RecoverOrigin(ctx context.Context, stream *domain.Stream) error {
+	origin := network.Get().Node(stream.Origin)
+	if origin.Name != stream.Origin {
+		return pkg.NewError(pkg.ErrNotFound, fmt.Errorf("origin node %s not found", stream.Origin))
+	}
+
+	backend := media.Node(origin.Name)
+	_, err := backend.PathStatus(ctx, OriginPath(stream.Id))
+	if err == nil || !errors.Is(err, pkg.ErrNotFound) {
+		return err
+	}
+
+	return backend.CreatePath(ctx, OriginPath(stream.Id), originSource(origin, stream.Id))
+}
+
+// originURL returns the rtsp url of the origin path of streamId
+func originURL(origin network.MediaMtx, streamId string) string {
+	return fmt.Sprintf("rtsp://%s:%d/%s", origin.Rtsp.Ip, origin.Rtsp.Port, OriginPath(streamId))
+}
+
+// releaseStream drops the references of a stream session which failed to
+// be created and returns err
+func releaseStream(ctx context.Context, stream *domain.Stream, err error) error {
+	if err := releaseTranscoder(ctx, stream); err != nil {
+		pkg.LogWarnContext(ctx, fmt.Sprintf("failed to release transcoder of %v: %v", *stream, err))
+	}
+	if err := releaseOrigin(ctx, stream); err != nil {
+		pkg.LogWarnContext(ctx, fmt.Sprintf("failed to release origin of %v: %v", *stream, err))
+	}
+	return err
+}
+
+// isMediaMtx reports whether the media backend is a mediamtx node pool
+func isMediaMtx() bool {
+	backend := network.Get().Media
+	return backend == "" || backend == media.BackendMediaMtx
+}
+
+// cameraSource returns the camera url of streamId served by a rtsp server
+func cameraSource(rtsp network.Rtsp, streamId string) string {
+	//* you must make a mapping between stream.id and rtsp.subpath ex => id: 001 to subpath: /74630a72-2612-478b-9c49-308c720aa619
+	//? Why ? Because, you should not stream a video from a server directly if stream.id==rtsp.subPath make it stream.id!=rtsp.subPath
+	//! Temporary, i make it to be the same
+	subPath := "/" + streamId
+	return fmt.Sprintf("rtsp://%s:%d%s%s",
+		rtsp.Ip,
+		rtsp.Port,
+		rtsp.Path,
+		subPath,
+	)
+}
+
+// originSource returns the camera url pulled by the origin path of a node
+func originSource(origin network.MediaMtx, streamId string) string {
+	if origin.Source != "" {
+		return strings.ReplaceAll(origin.Source, "{id}", streamId)
+	}
+	return cameraSource(origin.Rtsp, streamId)
+}
+
+// acquireOrigin references the origin path of streamId on the origin node,
+// the first reference adds the path pulling the camera.
+func acquireOrigin(ctx context.Context, origin network.MediaMtx, streamId string) error {
+	// References wait for the path added or deleted by another one
+	return withLock(ctx, "origin:"+origin.Name+":"+streamId, func() error {
+		return acquireOriginPath(ctx, origin, streamId)
+	})
+}
+
+func acquireOriginPath(ctx context.Context, origin network.MediaMtx, streamId string) error {
+	repo := repository.NewOrigin(ctx)
+	defer repo.Close()
+
+	count, err := repo.Acquire(origin.Name, streamId)
+	if err != nil {
+		return pkg.NewError(pkg.ErrProcessFail, err)
+	}
+	if count > 1 {
+		return nil
+	}
+
+	// A path left behind by a previous chain is reused
+	err = media.Node(origin.Name).CreatePath(ctx, OriginPath(streamId), originSource(origin, streamId))
+	if err != nil && !errors.Is(err, pkg.ErrBadRequest) {
+		if _, err := repo.Release(origin.Name, streamId); err != nil {
+			pkg.LogWarnContext(ctx, fmt.Sprintf("failed to release origin %s of %s: %v", origin.Name, streamId, err))
+		}
+		return err
+	}
+	pkg.LogInfoContext(ctx, fmt.Sprintf("origin of stream %s added on media node %s", streamId, origin.Name))
+
+	return nil
+}
+
+// releaseOrigin drops the reference of a stream session to its origin path,
+// the last reference deletes the path.
+func releaseOrigin(ctx context.Context, stream *domain.Stream) error {
+	if stream.Origin == "" {
+		return nil
+	}
+	return releaseOriginOf(ctx, stream.Origin, stream.Id)
+}
+
+// releaseOriginOf drops a reference to the origin path of streamId on node
+func releaseOriginOf(ctx context.Context, node, streamId string) error {
+	return withLock(ctx, "origin:"+node+":"+streamId, func() error {
+		return releaseOriginPath(ctx, node, streamId)
+	})
+}
+
+func releaseOriginPath(ctx context.Context, node, streamId string) error {
+	repo := repository.NewOrigin(ctx)
+	defer repo.Close()
+
+	count, err := repo.Release(node, streamId)
+	if err != nil {
+		return pkg.NewError(pkg.ErrProcessFail, err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	err = media.Node(node).DeletePath(ctx, OriginPath(streamId))
+	if err != nil && !errors.Is(err, pkg.ErrNotFound) {
+		return err
+	}
+	pkg.LogInfoContext(ctx, fmt.Sprintf("origin of stream %s removed from media node %s", streamId, node))
+
+	return nil
+}
+
+// lockTtl bounds a lock in case its holder dies
+const lockTtl = 30 * time.Second
+
+// withLock runs fn holding the lock of name, it waits for the holder until
+// ctx is done
+func withLock(ctx context.Context, name string, fn func() error) error {
+	// The lock is freed even when ctx is done
+	repo := repository.NewLock(context.WithoutCancel(ctx))
+	defer repo.Close()
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		token, ok, err := repo.Obtain(name, lockTtl)
+		if err != nil {
+			return pkg.NewError(pkg.ErrProcessFail, err)
+		}
+		if ok {
+			defer func() {
+				if err := repo.Release(name, token); err != nil {
+					pkg.LogWarnContext(ctx, fmt.Sprintf("failed to release lock %s: %v", name, err))
+				}
+			}()
+			return fn()
+		}
+
+		select {
+		case <-ctx.Done():
+			return pkg.NewError(pkg.ErrUnavailable, fmt.Errorf("lock %s: %w", name, ctx.Err()))
+		case <-ticker.C:
+		}
+	}
+}
+
+// newToken returns a random secret
+func newToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,217 @@
+package session
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"stream-session-api/domain"
+	"stream-session-api/internal/conf/network"
+	"stream-session-api/internal/repository"
+	"stream-session-api/pkg"
+	"strings"
+	"sync"
+	"time"
+)
+
+// snapshotCall is a frame grab in progress, shared by concurrent requests
+type snapshotCall struct {
+	done     chan struct{}
+	snapshot *domain.Snapshot
+	err      error
+}
+
+var (
+	snapshotMu    sync.Mutex
+	snapshotCalls = make(map[string]*snapshotCall)
+)
+
+// Snapshot returns a JPEG of the current frame of streamId scaled to width
+// and height, served from the cache for cache_ttl seconds, 0 disables it.
+// Concurrent requests of the same size share a single frame grab.
+func Snapshot(ctx context.Context, streamId string, width, height uint) (*domain.Snapshot, error) {
+	repo := repository.NewSnapshot(ctx)
+	defer repo.Close()
+
+	if snapshot := repo.Find(streamId, width, height); snapshot != nil {
+		return snapshot, nil
+	}
+
+	key := fmt.Sprintf("%s:%dx%d", streamId, width, height)
+	snapshotMu.Lock()
+	call, ok := snapshotCalls[key]
+	if !ok {
+		call = &snapshotCall{done: make(chan struct{})}
+		snapshotCalls[key] = call
+
+		// The grab outlives a canceled caller, others may wait for it
+		go func(ctx context.Context) {
+			call.snapshot, call.err = grabSnapshot(ctx, streamId, width, height)
+			ttl := time.Second * time.Duration(network.Get().Snapshot.CacheTtl)
+			if call.err == nil && ttl > 0 {
+				repo := repository.NewSnapshot(ctx)
+				defer repo.Close()
+
+				if err := repo.Save(call.snapshot, ttl); err != nil {
+					pkg.LogWarnContext(ctx, fmt.Sprintf("failed to cache snapshot of %s: %v", streamId, err))
+				}
+			}
+
+			snapshotMu.Lock()
+			delete(snapshotCalls, key)
+			snapshotMu.Unlock()
+			close(call.done)
+		}(context.WithoutCancel(ctx))
+	}
+	snapshotMu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.snapshot, call.err
+	case <-ctx.Done():
+		return nil, pkg.NewError(pkg.ErrUnavailable, ctx.Err())
+	}
+}
+
+// SignSnapshotURL returns the signed url of the snapshots of streamId and its
+// expiry, the image is refreshed on every request past the cache
+func SignSnapshotURL(streamId string, width, height uint) (string, time.Time, error) {
+	conf := network.Get()
+	if conf.Recording.Secret == "" {
+		return "", time.Time{}, pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("recording url secret not configured"))
+	}
+	expires := time.Now().Add(time.Second * time.Duration(conf.Snapshot.UrlTtl))
+
+	query := url.Values{}
+	query.Set("width", strconv.FormatUint(uint64(width), 10))
+	query.Set("height", strconv.FormatUint(uint64(height), 10))
+	query.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	query.Set("signature", snapshotSignature(streamId, query))
+
+	return fmt.Sprintf("%s/snapshots/%s?%s", conf.Http.BaseUrl(), url.PathEscape(streamId), query.Encode()), expires, nil
+}
+
+// VerifySnapshotURL checks the signature and expiry of a snapshot url and
+// returns the requested size
+func VerifySnapshotURL(streamId string, query url.Values) (uint, uint, error) {
+	// An empty secret would let anyone sign
+	signature := query.Get("signature")
+	if network.Get().Recording.Secret == "" || !hmac.Equal([]byte(signature), []byte(snapshotSignature(streamId, query))) {
+		return 0, 0, pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("invalid signature of snapshot %s", streamId))
+	}
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return 0, 0, pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("snapshot url of %s expired", streamId))
+	}
+	width, err := strconv.ParseUint(query.Get("width"), 10, 16)
+	if err != nil {
+		return 0, 0, pkg.NewError(pkg.ErrBadRequest, err)
+	}
+	height, err := strconv.ParseUint(query.Get("height"), 10, 16)
+	if err != nil {
+		return 0, 0, pkg.NewError(pkg.ErrBadRequest, err)
+	}
+
+	return uint(width), uint(height), nil
+}
+
+// grabSnapshot decodes the next keyframe of the camera of streamId with ffmpeg
+func grabSnapshot(ctx context.Context, streamId string, width, height uint) (*domain.Snapshot, error) {
+	conf := network.Get().Snapshot
+
+	source, err := snapshotSource(ctx, streamId)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := time.Second * time.Duration(conf.Timeout)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	args := []string{"-hide_banner", "-loglevel", "error", "-skip_frame", "nokey"}
+	if strings.HasPrefix(source, "rtsp") {
+		args = append(args, "-rtsp_transport", "tcp")
+	}
+	args = append(args, "-i", source, "-frames:v", "1")
+	if filter := scaleFilter(width, height); filter != "" {
+		args = append(args, "-vf", filter)
+	}
+	args = append(args, "-f", "image2", "-c:v", "mjpeg", "pipe:1")
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, conf.Ffmpeg, args...)
+	cmd.Stderr = &stderr
+
+	image, err := cmd.Output()
+	if ctx.Err() != nil {
+		return nil, pkg.NewError(pkg.ErrUnavailable, fmt.Errorf("no frame of %s after %s", streamId, timeout))
+	}
+	if err != nil {
+		return nil, pkg.NewError(pkg.ErrProcessFail, fmt.Errorf("ffmpeg: %v: %s", err, strings.TrimSpace(stderr.String())))
+	}
+	if len(image) == 0 {
+		return nil, pkg.NewError(pkg.ErrUnavailable, fmt.Errorf("no frame of %s", streamId))
+	}
+
+	return &domain.Snapshot{
+		StreamId: streamId,
+		Width:    width,
+		Height:   height,
+		Image:    image,
+		TakenAt:  time.Now(),
+	}, nil
+}
+
+// snapshotSource returns the url the frames of streamId are grabbed from: the
+// origin path relayed by its sessions, else the camera
+func snapshotSource(ctx context.Context, streamId string) (string, error) {
+	conf := network.Get()
+	if !isMediaMtx() {
+		return cameraSource(conf.Node("").Rtsp, streamId), nil
+	}
+
+	repo := repository.NewStream(ctx)
+	defer repo.Close()
+
+	streams, err := repo.GetAll()
+	if err != nil {
+		return "", pkg.NewError(pkg.ErrProcessFail, err)
+	}
+	for _, stream := range streams {
+		if stream.Id == streamId && stream.Origin != "" {
+			return originURL(conf.Node(stream.Origin), streamId), nil
+		}
+	}
+
+	origin, ok := conf.Origin(streamId)
+	if !ok {
+		origin = conf.Node("")
+	}
+	return originSource(origin, streamId), nil
+}
+
+// scaleFilter returns the ffmpeg scale filter fitting the frames in width and
+// height, a zero side keeps the aspect ratio
+func scaleFilter(width, height uint) string {
+	switch {
+	case width == 0 && height == 0:
+		return ""
+	case width == 0:
+		return fmt.Sprintf("scale=-2:%d", height)
+	case height == 0:
+		return fmt.Sprintf("scale=%d:-2", width)
+	}
+	return fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease:force_divisible_by=2", width, height)
+}
+
+// snapshotSignature signs the size and expiry of a snapshot url
+func snapshotSignature(streamId string, query url.Values) string {
+	mac := hmac.New(sha256.New, []byte(network.Get().Recording.Secret))
+	mac.Write([]byte("snapshot\n" + streamId + "\n" + query.Get("width") + "\n" + query.Get("height") + "\n" + query.Get("expires")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,229 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"stream-session-api/domain"
+	"stream-session-api/internal/conf/network"
+	"stream-session-api/internal/media"
+	"stream-session-api/internal/repository"
+	"stream-session-api/pkg"
+	"strings"
+	"time"
+)
+
+// TranscodePath returns the name of the path republishing the origin path of
+// streamId with a profile
+func TranscodePath(streamId, profile string) string {
+	return "transcode-" + profile + "-" + streamId
+}
+
+// IsTranscodePath reports whether a path is published by a transcoder
+func IsTranscodePath(path string) bool {
+	return strings.HasPrefix(path, "transcode-")
+}
+
+// AuthorizeTranscoder checks a transcoder path is published by the ffmpeg
+// run by its media node: from the loopback of the node, to a transcoder
+// still referenced. The command line of ffmpeg holds no credential.
+func AuthorizeTranscoder(ctx context.Context, path, ip string) error {
+	if address := net.ParseIP(ip); address == nil || !address.IsLoopback() {
+		return pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("transcoder %s published from %s", path, ip))
+	}
+
+	repo := repository.NewTranscoder(ctx)
+	defer repo.Close()
+
+	transcoders, err := repo.GetAll()
+	if err != nil {
+		return pkg.NewError(pkg.ErrProcessFail, err)
+	}
+	for _, transcoder := range transcoders {
+		if transcoder.Path == path {
+			return nil
+		}
+	}
+
+	return pkg.NewError(pkg.ErrBadRequest, fmt.Errorf("transcoder %s not found", path))
+}
+
+// RecoverTranscoder re-creates the transcoder path read by a stream session
+// when it is missing, e.g. after the origin node restarted.
+func RecoverTranscoder(ctx context.Context, stream *domain.Stream) error {
+	if stream.Profile == "" {
+		return nil
+	}
+
+	repo := repository.NewTranscoder(ctx)
+	defer repo.Close()
+
+	path := TranscodePath(stream.Id, stream.Profile)
+	transcoder := repo.FindByPath(stream.Origin, path)
+	if transcoder == nil {
+		return pkg.NewError(pkg.ErrNotFound, fmt.Errorf("transcoder %s not found", path))
+	}
+	profile, ok := network.Get().Transcoding.Profile(stream.Profile)
+	if !ok {
+		return pkg.NewError(pkg.ErrNotFound, fmt.Errorf("profile %s not found", stream.Profile))
+	}
+
+	backend := media.Node(stream.Origin)
+	_, err := backend.PathStatus(ctx, path)
+	if err == nil || !errors.Is(err, pkg.ErrNotFound) {
+		return err
+	}
+
+	return backend.CreateCommandPath(ctx, path, transcodeCommand(transcoder, profile))
+}
+
+// acquireTranscoder references the transcoder of streamId with a profile on
+// the origin node, the first reference adds the path running ffmpeg.
+func acquireTranscoder(ctx context.Context, origin network.MediaMtx, streamId string, profile network.Profile) error {
+	// References wait for the transcoder added or deleted by another one
+	path := TranscodePath(streamId, profile.Name)
+	return withLock(ctx, "transcoder:"+origin.Name+":"+path, func() error {
+		return acquireTranscodePath(ctx, origin, streamId, profile)
+	})
+}
+
+func acquireTranscodePath(ctx context.Context, origin network.MediaMtx, streamId string, profile network.Profile) error {
+	repo := repository.NewTranscoder(ctx)
+	defer repo.Close()
+
+	path := TranscodePath(streamId, profile.Name)
+	count, err := repo.Acquire(origin.Name, path)
+	if err != nil {
+		return pkg.NewError(pkg.ErrProcessFail, err)
+	}
+	if count > 1 {
+		return nil
+	}
+
+	transcoder := &domain.Transcoder{
+		Node:      origin.Name,
+		Path:      path,
+		StreamId:  streamId,
+		Profile:   profile.Name,
+		CreatedAt: time.Now(),
+	}
+	err = repo.Insert(transcoder)
+	if err != nil {
+		err = pkg.NewError(pkg.ErrProcessFail, err)
+	} else {
+		err = createTranscodePath(ctx, transcoder, profile)
+	}
+	if err != nil {
+		if _, err := repo.Release(origin.Name, path); err != nil {
+			pkg.LogWarnContext(ctx, fmt.Sprintf("failed to release transcoder %s on %s: %v", path, origin.Name, err))
+		}
+		return err
+	}
+	pkg.LogInfoContext(ctx, fmt.Sprintf("transcoder %s added on media node %s", path, origin.Name))
+
+	return nil
+}
+
+// createTranscodePath adds the path of a transcoder, a path left behind by a
+// previous transcoder may run another profile and is replaced
+func createTranscodePath(ctx context.Context, transcoder *domain.Transcoder, profile network.Profile) error {
+	backend := media.Node(transcoder.Node)
+	command := transcodeCommand(transcoder, profile)
+
+	err := backend.CreateCommandPath(ctx, transcoder.Path, command)
+	if !errors.Is(err, pkg.ErrBadRequest) {
+		return err
+	}
+	if err := backend.DeletePath(ctx, transcoder.Path); err != nil && !errors.Is(err, pkg.ErrNotFound) {
+		return err
+	}
+	return backend.CreateCommandPath(ctx, transcoder.Path, command)
+}
+
+// releaseTranscoder drops the reference of a stream session to its
+// transcoder, the last reference deletes the path.
+func releaseTranscoder(ctx context.Context, stream *domain.Stream) error {
+	if stream.Profile == "" {
+		return nil
+	}
+
+	path := TranscodePath(stream.Id, stream.Profile)
+	return withLock(ctx, "transcoder:"+stream.Origin+":"+path, func() error {
+		return releaseTranscodePath(ctx, stream)
+	})
+}
+
+func releaseTranscodePath(ctx context.Context, stream *domain.Stream) error {
+	repo := repository.NewTranscoder(ctx)
+	defer repo.Close()
+
+	path := TranscodePath(stream.Id, stream.Profile)
+	count, err := repo.Release(stream.Origin, path)
+	if err != nil {
+		return pkg.NewError(pkg.ErrProcessFail, err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	if err := repo.Delete(stream.Origin, path); err != nil {
+		return pkg.NewError(pkg.ErrProcessFail, err)
+	}
+	err = media.Node(stream.Origin).DeletePath(ctx, path)
+	if err != nil && !errors.Is(err, pkg.ErrNotFound) {
+		return err
+	}
+	pkg.LogInfoContext(ctx, fmt.Sprintf("transcoder %s removed from media node %s", path, stream.Origin))
+
+	return nil
+}
+
+// transcodeCommand returns the ffmpeg command run by MediaMTX next to the
+// origin path, it republishes the origin path to the transcoder path
+func transcodeCommand(transcoder *domain.Transcoder, profile network.Profile) string {
+	args := []string{
+		network.Get().Transcoding.Ffmpeg, "-hide_banner", "-loglevel", "error",
+		"-rtsp_transport", "tcp", "-i", "rtsp://localhost:$RTSP_PORT/" + OriginPath(transcoder.StreamId),
+	}
+
+	// Size and frame rate
+	var filters []string
+	if filter := scaleFilter(profile.Width, profile.Height); filter != "" {
+		filters = append(filters, filter)
+	}
+	if profile.Fps > 0 {
+		filters = append(filters, fmt.Sprintf("fps=%d", profile.Fps))
+	}
+	if len(filters) > 0 {
+		args = append(args, "-vf", strings.Join(filters, ","))
+	}
+
+	// Encoder tuned for live viewers, a keyframe every 2 seconds
+	switch profile.Codec {
+	case "h265":
+		args = append(args, "-c:v", "libx265", "-preset", "veryfast", "-tune", "zerolatency")
+	case "vp8":
+		args = append(args, "-c:v", "libvpx", "-deadline", "realtime", "-cpu-used", "8")
+	case "vp9":
+		args = append(args, "-c:v", "libvpx-vp9", "-deadline", "realtime", "-cpu-used", "8")
+	default: // h264, profiles are validated when the config is read
+		args = append(args, "-c:v", "libx264", "-preset", "veryfast", "-tune", "zerolatency", "-bf", "0")
+	}
+	gop := profile.Fps * 2
+	if gop == 0 {
+		gop = 50
+	}
+	args = append(args,
+		"-pix_fmt", "yuv420p", "-g", fmt.Sprint(gop),
+		"-b:v", fmt.Sprintf("%dk", profile.Bitrate),
+		"-maxrate", fmt.Sprintf("%dk", profile.Bitrate),
+		"-bufsize", fmt.Sprintf("%dk", profile.Bitrate*2),
+		"-c:a", "copy",
+	)
+
+	// Publish from the loopback, checked by the auth endpoint
+	args = append(args, "-f", "rtsp", "-rtsp_transport", "tcp", "rtsp://localhost:$RTSP_PORT/$MTX_PATH")
+
+	return strings.Join(args, " ")
+}
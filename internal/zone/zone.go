@@ -0,0 +1,96 @@
+package zone
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strconv"
+	"stream-session-api/domain"
+	"stream-session-api/internal/conf/network"
+	"stream-session-api/pkg"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// Caller returns the address of the grpc caller. Behind trusted proxies it
+// is the last X-Forwarded-For entry not added by one of them.
+func Caller(ctx context.Context) net.IP {
+	client, ok := peer.FromContext(ctx)
+	if !ok || client.Addr == nil {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(client.Addr.String())
+	if err != nil {
+		host = client.Addr.String()
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil
+	}
+
+	// Any other peer may forge the header
+	md, _ := metadata.FromIncomingContext(ctx)
+	return pkg.ForwardedAddress(ip, md.Get("x-forwarded-for"), network.Get().Grpc.Trusted)
+}
+
+// Match returns the first zone whose networks contain ip, nil if none
+func Match(ip net.IP) *network.Zone {
+	if ip == nil {
+		return nil
+	}
+
+	zones := network.Get().Zones
+	for i := range zones {
+		if network.Contains(zones[i].Networks, ip) {
+			return &zones[i]
+		}
+	}
+
+	return nil
+}
+
+// FromContext returns the zone of the grpc caller, nil if none
+func FromContext(ctx context.Context) *network.Zone {
+	return Match(Caller(ctx))
+}
+
+// Rewrite returns the url of protocol served by node as reachable from
+// zone. The host is replaced for every protocol, the scheme, port and
+// path prefix only for http ones.
+func Rewrite(zone *network.Zone, node, protocol, rawUrl string) string {
+	if zone == nil {
+		return rawUrl
+	}
+
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return rawUrl
+	}
+
+	host, port := u.Hostname(), u.Port()
+	if zone.Host != "" {
+		host = strings.ReplaceAll(zone.Host, "{node}", node)
+	}
+
+	switch protocol {
+	case domain.ProtocolWhep, domain.ProtocolWhip, domain.ProtocolWebRtc, domain.ProtocolHls, domain.ProtocolLlHls:
+		if zone.Scheme != "" {
+			u.Scheme = zone.Scheme
+		}
+		if zone.Port != 0 {
+			port = strconv.FormatUint(uint64(zone.Port), 10)
+		}
+		if prefix := strings.Trim(strings.ReplaceAll(zone.PathPrefix, "{node}", node), "/"); prefix != "" {
+			u.Path = "/" + prefix + u.Path
+		}
+	}
+
+	u.Host = host
+	if port != "" {
+		u.Host = net.JoinHostPort(host, port)
+	}
+
+	return u.String()
+}
@@ -0,0 +1,119 @@
+package zone
+
+import (
+	"context"
+	"net"
+	"stream-session-api/domain"
+	"stream-session-api/internal/conf/network"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// setNetworks configures the trusted proxies and zones for a test
+func setNetworks(t *testing.T, proxies string, zones ...network.Zone) {
+	conf := network.Get()
+	t.Cleanup(func() { network.Set(conf) })
+
+	var err error
+	next := conf
+	next.Grpc.Proxies, err = network.ParseCidrs([]string{proxies})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range zones {
+		zones[i].Networks, err = network.ParseCidrs(zones[i].Cidrs)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	next.Zones = zones
+	network.Set(next)
+}
+
+func TestCaller(t *testing.T) {
+	setNetworks(t, "10.0.0.0/24")
+
+	tests := []struct {
+		name      string
+		peer      string
+		forwarded []string
+		want      string
+	}{
+		{name: "direct", peer: "203.0.113.7:4000", want: "203.0.113.7"},
+		{name: "forged by a client", peer: "203.0.113.7:4000", forwarded: []string{"10.8.0.5"}, want: "203.0.113.7"},
+		{name: "trusted proxy", peer: "10.0.0.2:4000", forwarded: []string{"198.51.100.9"}, want: "198.51.100.9"},
+		{name: "trusted proxy without header", peer: "10.0.0.2:4000", want: "10.0.0.2"},
+		{name: "forged entry before the client", peer: "10.0.0.2:4000", forwarded: []string{"10.8.0.5, 198.51.100.9"}, want: "198.51.100.9"},
+		{name: "chain of trusted proxies", peer: "10.0.0.2:4000", forwarded: []string{"198.51.100.9, 10.0.0.3"}, want: "198.51.100.9"},
+		{name: "repeated header", peer: "10.0.0.2:4000", forwarded: []string{"198.51.100.9", "10.0.0.3"}, want: "198.51.100.9"},
+		{name: "invalid entry", peer: "10.0.0.2:4000", forwarded: []string{"198.51.100.9, unknown"}, want: "10.0.0.2"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			addr, err := net.ResolveTCPAddr("tcp", test.peer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: addr})
+			if test.forwarded != nil {
+				md := metadata.MD{}
+				md.Append("x-forwarded-for", test.forwarded...)
+				ctx = metadata.NewIncomingContext(ctx, md)
+			}
+
+			if got := Caller(ctx); got.String() != test.want {
+				t.Fatalf("Caller = %s, want %s", got, test.want)
+			}
+		})
+	}
+
+	if got := Caller(context.Background()); got != nil {
+		t.Fatalf("Caller without peer = %s, want nil", got)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	setNetworks(t, "10.0.0.0/24",
+		network.Zone{Name: "vpn", Cidrs: []string{"10.8.0.0/16"}},
+		network.Zone{Name: "internet", Cidrs: []string{"0.0.0.0/0"}},
+	)
+
+	if zone := Match(net.ParseIP("10.8.1.2")); zone == nil || zone.Name != "vpn" {
+		t.Fatalf("Match = %v, want vpn", zone)
+	}
+	if zone := Match(net.ParseIP("203.0.113.7")); zone == nil || zone.Name != "internet" {
+		t.Fatalf("Match = %v, want internet", zone)
+	}
+	if zone := Match(nil); zone != nil {
+		t.Fatalf("Match of no address = %v, want nil", zone)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	zone := &network.Zone{Host: "{node}.example.com", Scheme: "https", Port: 443, PathPrefix: "/media/{node}/"}
+
+	tests := []struct {
+		name     string
+		zone     *network.Zone
+		protocol string
+		url      string
+		want     string
+	}{
+		{name: "no zone", protocol: domain.ProtocolWhep, url: "http://10.1.0.2:8889/uuid/whep", want: "http://10.1.0.2:8889/uuid/whep"},
+		{name: "whep", zone: zone, protocol: domain.ProtocolWhep, url: "http://10.1.0.2:8889/uuid/whep", want: "https://edge-1.example.com:443/media/edge-1/uuid/whep"},
+		{name: "hls", zone: zone, protocol: domain.ProtocolHls, url: "http://10.1.0.2:8888/uuid/index.m3u8", want: "https://edge-1.example.com:443/media/edge-1/uuid/index.m3u8"},
+		{name: "rtsp keeps scheme port and path", zone: zone, protocol: domain.ProtocolRtsp, url: "rtsp://10.1.0.2:8554/uuid", want: "rtsp://edge-1.example.com:8554/uuid"},
+		{name: "host only", zone: &network.Zone{Host: "stream.example.com"}, protocol: domain.ProtocolWhep, url: "http://10.1.0.2:8889/uuid/whep", want: "http://stream.example.com:8889/uuid/whep"},
+		{name: "no port", zone: &network.Zone{Host: "stream.example.com"}, protocol: domain.ProtocolHls, url: "http://10.1.0.2/uuid/index.m3u8", want: "http://stream.example.com/uuid/index.m3u8"},
+		{name: "invalid url", zone: zone, protocol: domain.ProtocolWhep, url: "http://[::1", want: "http://[::1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Rewrite(test.zone, "edge-1", test.protocol, test.url); got != test.want {
+				t.Fatalf("Rewrite = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
@@ -16,6 +16,7 @@ var (
 	ErrUnsupportedOs    = errors.New("unsupported os")
 	ErrInternalFailure  = errors.New("internal failure")
 	ErrProcessFail      = errors.New("process fail")
+	ErrUnavailable      = errors.New("unavailable")
 )
 
 type Error struct {
@@ -41,3 +42,8 @@ func (e Error) SvcError() error {
 func (e Error) Error() string {
 	return errors.Join(e.svcError, e.appError).Error()
 }
+
+// Unwrap allows errors.Is and errors.As to match both errors
+func (e Error) Unwrap() []error {
+	return []error{e.svcError, e.appError}
+}
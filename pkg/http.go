@@ -0,0 +1,57 @@
+package pkg
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// RequestToken returns the bearer token of a request, or its token query
+func RequestToken(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return r.URL.Query().Get("token")
+}
+
+// RequestAddress returns the client address of a request. Behind trusted
+// proxies it is the last X-Forwarded-For entry not added by one of them.
+func RequestAddress(r *http.Request, trusted func(net.IP) bool) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return host
+	}
+	return ForwardedAddress(ip, r.Header.Values("X-Forwarded-For"), trusted).String()
+}
+
+// ForwardedAddress returns the client address of a connection from peer.
+// The forwarded entries are only honored when peer is trusted, walking them
+// back to the last one not added by a trusted proxy, any other peer may
+// forge them.
+func ForwardedAddress(peer net.IP, forwarded []string, trusted func(net.IP) bool) net.IP {
+	if !trusted(peer) {
+		return peer
+	}
+
+	var hops []string
+	for _, value := range forwarded {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+	ip := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			break
+		}
+		ip = hop
+		if !trusted(hop) {
+			break
+		}
+	}
+
+	return ip
+}
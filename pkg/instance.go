@@ -0,0 +1,22 @@
+package pkg
+
+import (
+	"os"
+	"sync"
+)
+
+var (
+	instanceId   string
+	instanceOnce sync.Once
+)
+
+// InstanceId identifies this running instance, INSTANCE_ID or the hostname
+func InstanceId() string {
+	instanceOnce.Do(func() {
+		instanceId = os.Getenv("INSTANCE_ID")
+		if instanceId == "" {
+			instanceId, _ = os.Hostname()
+		}
+	})
+	return instanceId
+}
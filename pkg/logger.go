@@ -1,14 +1,18 @@
 package pkg
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"runtime"
 	"sync"
+	"testing"
 
 	"github.com/charmbracelet/log"
 	"github.com/joho/godotenv"
+	"go.opentelemetry.io/otel/trace"
 	"gopkg.in/natefinch/lumberjack.v2"
 )
 
@@ -23,7 +27,7 @@ var LogFile *lumberjack.Logger
 
 // init initializes the logger instance.
 func init() {
-	err := godotenv.Load(".env")
+	err := loadEnv()
 	if err != nil {
 		log.Fatal("Failed to read .env")
 		os.Exit(1)
@@ -73,6 +77,27 @@ func init() {
 	})
 }
 
+// loadEnv loads the .env of the working directory. Tests run from their
+// package directory, they load the .env of the module root instead.
+func loadEnv() error {
+	err := godotenv.Load(".env")
+	if err == nil || !testing.Testing() {
+		return err
+	}
+
+	dir, _ := os.Getwd()
+	for {
+		if _, statErr := os.Stat(filepath.Join(dir, "go.mod")); statErr == nil {
+			return godotenv.Load(filepath.Join(dir, ".env"))
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return err
+		}
+		dir = parent
+	}
+}
+
 func LogInfo(msg interface{}, keyvals ...interface{}) {
 	logger.Info(msg, keyvals...)
 }
@@ -92,3 +117,28 @@ func LogError(msg interface{}, keyvals ...interface{}) {
 func LogFatal(msg interface{}, keyvals ...interface{}) {
 	logger.Fatal(msg, keyvals...)
 }
+
+// traceKeyvals prepends the trace and span id of ctx to keyvals
+func traceKeyvals(ctx context.Context, keyvals []interface{}) []interface{} {
+	spanCtx := trace.SpanContextFromContext(ctx)
+	if !spanCtx.IsValid() {
+		return keyvals
+	}
+	return append([]interface{}{"trace_id", spanCtx.TraceID().String(), "span_id", spanCtx.SpanID().String()}, keyvals...)
+}
+
+func LogInfoContext(ctx context.Context, msg interface{}, keyvals ...interface{}) {
+	logger.Info(msg, traceKeyvals(ctx, keyvals)...)
+}
+
+func LogDebugContext(ctx context.Context, msg interface{}, keyvals ...interface{}) {
+	logger.Debug(msg, traceKeyvals(ctx, keyvals)...)
+}
+
+func LogWarnContext(ctx context.Context, msg interface{}, keyvals ...interface{}) {
+	logger.Warn(msg, traceKeyvals(ctx, keyvals)...)
+}
+
+func LogErrorContext(ctx context.Context, msg interface{}, keyvals ...interface{}) {
+	logger.Error(msg, traceKeyvals(ctx, keyvals)...)
+}
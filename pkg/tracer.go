@@ -0,0 +1,92 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/go-resty/resty/v2"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracer name used by every span created by the application
+const tracerName = "stream-session-api"
+
+// InitTracer sets the global tracer provider and propagator.
+// The exporter is selected by TRACING_EXPORTER (none, stdout or otlp).
+func InitTracer(ctx context.Context) (func(context.Context) error, error) {
+	// Propagate w3c trace context from incoming metadata and headers
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch os.Getenv("TRACING_EXPORTER") {
+	case "", "none":
+		// Tracing disabled, keep the no-op global provider
+		return func(context.Context) error { return nil }, nil
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case "otlp":
+		opts := []otlptracegrpc.Option{
+			otlptracegrpc.WithEndpoint(os.Getenv("TRACING_OTLP_ENDPOINT")),
+		}
+		if insecure, _ := strconv.ParseBool(os.Getenv("TRACING_OTLP_INSECURE")); insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		exporter, err = otlptracegrpc.New(ctx, opts...)
+	default:
+		return nil, NewError(ErrBadRequest, fmt.Errorf("unknown tracing exporter %q", os.Getenv("TRACING_EXPORTER")))
+	}
+	if err != nil {
+		return nil, NewError(ErrInternalFailure, err)
+	}
+
+	// Sample ratio, default to every trace
+	ratio, err := strconv.ParseFloat(os.Getenv("TRACING_SAMPLE_RATIO"), 64)
+	if err != nil {
+		ratio = 1
+	}
+
+	res := resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(os.Getenv("APPLICATION_NAME")),
+		semconv.ServiceVersion(os.Getenv("APPLICATION_VERSION")),
+	)
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// StartSpan starts a span from the application tracer
+func StartSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, opts...)
+}
+
+// NewHttpClient returns a resty client whose requests are traced
+func NewHttpClient() *resty.Client {
+	transport := otelhttp.NewTransport(http.DefaultTransport,
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + r.URL.Path
+		}),
+	)
+	return resty.New().SetTransport(transport)
+}